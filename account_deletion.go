@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// handlerDeleteUser schedules the caller's account for deletion. The account
+// and its chirps disappear from view straight away, but nothing is removed
+// until the grace period is over, and logging in before then cancels it.
+func (cfg *apiConfig) handlerDeleteUser(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	type response struct {
+		DeletionScheduledFor time.Time `json:"deletion_scheduled_for"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+
+	emailKey := emailThrottleKey(dbUser.Email)
+	ipKey := ipThrottleKey(cfg.clientIP(r))
+
+	wait, err := cfg.reserveLoginAttempt(r.Context(), emailKey, ipKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
+	// an access token alone isn't enough, the user has to sign in again
+	_, err = auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "incorrect password or code", err)
+		return
+	}
+
+	if dbUser.TotpEnabledAt.Valid {
+		ok, err := cfg.checkMFACode(r.Context(), dbUser, params.Code)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to check code", err)
+			return
+		}
+		if !ok {
+			respondWithError(w, http.StatusUnauthorized, "incorrect password or code", nil)
+			return
+		}
+	}
+	cfg.releaseLoginAttempt(r.Context(), emailKey, ipKey)
+
+	dbUser, err = cfg.db.RequestUserDeletion(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to schedule deletion", err)
+		return
+	}
+
+	err = cfg.db.RevokeUserRefreshTokens(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions", err)
+		return
+	}
+
+	err = cfg.revokeUserAccessTokens(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions", err)
+		return
+	}
+
+	err = cfg.db.RevokeUserPersonalAccessTokens(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke tokens", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{
+		DeletionScheduledFor: dbUser.DeletionRequestedAt.Time.Add(cfg.accountDeletionGrace),
+	})
+}
+
+// cancelUserDeletion is called when a user whose account is waiting to be
+// deleted signs in again.
+func (cfg *apiConfig) cancelUserDeletion(ctx context.Context, userID uuid.UUID) error {
+	err := cfg.db.CancelUserDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("user %s signed in, cancelled account deletion", userID)
+	return nil
+}
+
+// deleteExpiredAccounts removes accounts whose grace period has run out.
+func (cfg *apiConfig) deleteExpiredAccounts(ctx context.Context) {
+	rows, err := cfg.db.DeleteUsersRequestedBefore(ctx, sql.NullTime{
+		Time:  time.Now().Add(-cfg.accountDeletionGrace),
+		Valid: true,
+	})
+	if err != nil {
+		log.Printf("failed to delete accounts: %s", err)
+		return
+	}
+	if rows > 0 {
+		log.Printf("deleted %d accounts at the end of their grace period", rows)
+	}
+}
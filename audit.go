@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Security-sensitive events are written to audit_events, which the database
+// only lets us append to.
+const (
+	auditLogin           = "login"
+	auditLoginFailed     = "login.failed"
+	auditEmailChanged    = "user.email_changed"
+	auditPasswordChanged = "user.password_changed"
+	auditUserUpgraded    = "user.upgraded"
+	auditRoleChanged     = "user.role_changed"
+	auditSessionRevoked  = "session.revoked"
+	auditSessionsRevoked = "sessions.revoked_all"
+	auditTokenCreated    = "token.created"
+	auditTokenRevoked    = "token.revoked"
+	auditAdminReset      = "admin.reset"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+type AuditEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Type      string          `json:"type"`
+	ActorID   *uuid.UUID      `json:"actor_id"`
+	TargetID  *uuid.UUID      `json:"target_id"`
+	IPAddress string          `json:"ip_address"`
+	UserAgent string          `json:"user_agent"`
+	Details   json.RawMessage `json:"details"`
+}
+
+// audit records an event. actorID is whoever did it and targetID whoever it
+// was done to; either is uuid.Nil when there isn't one, like a webhook or a
+// login for an unknown email. Failing to write the event is logged but
+// doesn't fail the request.
+func (cfg *apiConfig) audit(r *http.Request, eventType string, actorID, targetID uuid.UUID, details map[string]interface{}) {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		log.Printf("failed to encode %s audit event: %s", eventType, err)
+		return
+	}
+
+	err = cfg.db.InsertAuditEvent(r.Context(), database.InsertAuditEventParams{
+		EventType: eventType,
+		ActorID:   nullUUID(actorID),
+		TargetID:  nullUUID(targetID),
+		IpAddress: cfg.clientIP(r),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		Details:   data,
+	})
+	if err != nil {
+		log.Printf("failed to record %s audit event: %s", eventType, err)
+	}
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// handlerMyAudit lists the events a user did or had done to their account.
+// Where someone else did it, like an admin changing their role, where that
+// person was isn't the user's business, so the IP address and user agent are
+// left out.
+func (cfg *apiConfig) handlerMyAudit(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageSize(r, defaultAuditPageSize, maxAuditPageSize)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+		return
+	}
+
+	dbEvents, err := cfg.db.ListUserAuditEvents(r.Context(), database.ListUserAuditEventsParams{
+		UserID:     p.UserID,
+		MaxResults: limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	events := databaseAuditEventsToApi(dbEvents)
+	for i := range events {
+		if events[i].ActorID == nil || *events[i].ActorID != p.UserID {
+			events[i].IPAddress = ""
+			events[i].UserAgent = ""
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+// handlerAdminAudit searches every event. It can be filtered by type,
+// actor_id, target_id and a since/until time range.
+func (cfg *apiConfig) handlerAdminAudit(w http.ResponseWriter, r *http.Request, _ principal) {
+	query := r.URL.Query()
+	params := database.ListAuditEventsParams{}
+
+	if eventType := query.Get("type"); eventType != "" {
+		params.EventType = sql.NullString{String: eventType, Valid: true}
+	}
+
+	for name, dst := range map[string]*uuid.NullUUID{"actor_id": &params.ActorID, "target_id": &params.TargetID} {
+		s := query.Get(name)
+		if s == "" {
+			continue
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid "+name, err)
+			return
+		}
+		*dst = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	for name, dst := range map[string]*sql.NullTime{"since": &params.Since, "until": &params.Until} {
+		s := query.Get(name)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, name+" must be an RFC 3339 time", err)
+			return
+		}
+		*dst = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+
+	limit, ok := pageSize(r, defaultAuditPageSize, maxAuditPageSize)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+		return
+	}
+	params.MaxResults = limit
+
+	dbEvents, err := cfg.db.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseAuditEventsToApi(dbEvents))
+}
+
+func databaseAuditEventsToApi(dbEvents []database.AuditEvent) []AuditEvent {
+	events := make([]AuditEvent, len(dbEvents))
+	for i, dbEvent := range dbEvents {
+		events[i] = AuditEvent{
+			ID:        dbEvent.ID,
+			CreatedAt: dbEvent.CreatedAt,
+			Type:      dbEvent.EventType,
+			IPAddress: dbEvent.IpAddress,
+			UserAgent: dbEvent.UserAgent,
+			Details:   dbEvent.Details,
+		}
+		if dbEvent.ActorID.Valid {
+			events[i].ActorID = &dbEvent.ActorID.UUID
+		}
+		if dbEvent.TargetID.Valid {
+			events[i].TargetID = &dbEvent.TargetID.UUID
+		}
+	}
+	return events
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// principal is whoever made a request, signed in either with an access token
+// or with a personal access token. ClientID is set when an OAuth client is
+// acting on the user's behalf.
+type principal struct {
+	UserID    uuid.UUID
+	SessionID string
+	ClientID  string
+	// scopes is nil for the user's own access tokens, which can do anything
+	// the user can
+	scopes        []string
+	personalToken bool
+}
+
+// delegated reports whether the request came from a personal access token or
+// an OAuth client rather than the user's own session.
+func (p principal) delegated() bool {
+	return p.personalToken || p.ClientID != ""
+}
+
+func (p principal) hasScope(scope string) bool {
+	if p.scopes == nil {
+		return true
+	}
+	for _, s := range p.scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate accepts either kind of bearer token, or a browser's session
+// cookie.
+func (cfg *apiConfig) authenticate(r *http.Request) (principal, error) {
+	if cfg.usesCookieSession(r) {
+		return cfg.authenticateCookie(r)
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return principal{}, err
+	}
+
+	if auth.IsPersonalAccessToken(token) {
+		return cfg.authenticatePersonalAccessToken(r, token)
+	}
+
+	claims, err := cfg.keys.ParseDelegatedJWT(token)
+	if err != nil {
+		return principal{}, err
+	}
+
+	p := principal{
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		ClientID:  claims.ClientID,
+	}
+	if claims.ClientID != "" {
+		p.scopes = append([]string{}, auth.ParseScope(claims.Scope)...)
+	}
+
+	return p, nil
+}
+
+func (cfg *apiConfig) authenticatePersonalAccessToken(r *http.Request, token string) (principal, error) {
+	dbToken, err := cfg.db.GetPersonalAccessToken(r.Context(), auth.HashToken(token, cfg.secret))
+	if err != nil {
+		return principal{}, err
+	}
+	if dbToken.RevokedAt.Valid {
+		return principal{}, errors.New("token has been revoked")
+	}
+	if dbToken.ExpiresAt.Valid && dbToken.ExpiresAt.Time.Before(time.Now()) {
+		return principal{}, errors.New("token has expired")
+	}
+
+	err = cfg.db.TouchPersonalAccessToken(r.Context(), dbToken.ID)
+	if err != nil {
+		log.Printf("failed to update last use of token %s: %s", dbToken.TokenPrefix, err)
+	}
+
+	return principal{
+		UserID:        dbToken.UserID,
+		scopes:        dbToken.Scopes,
+		personalToken: true,
+	}, nil
+}
+
+// authorize authenticates the request and checks it was granted scope. On
+// failure it writes the error response and returns false.
+func (cfg *apiConfig) authorize(w http.ResponseWriter, r *http.Request, scope string) (principal, bool) {
+	p, err := cfg.authenticate(r)
+	if errors.Is(err, errCSRF) {
+		respondWithError(w, http.StatusForbidden, err.Error(), nil)
+		return principal{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+		return principal{}, false
+	}
+
+	if !p.hasScope(scope) {
+		respondWithError(w, http.StatusForbidden, "token is missing the "+scope+" scope", nil)
+		return principal{}, false
+	}
+
+	return p, true
+}
+
+// requireSession authenticates a request that has to come from a logged in
+// session, such as minting tokens. Personal access tokens and tokens issued
+// to OAuth clients aren't accepted.
+func (cfg *apiConfig) requireSession(w http.ResponseWriter, r *http.Request) (principal, bool) {
+	p, err := cfg.authenticate(r)
+	if errors.Is(err, errCSRF) {
+		respondWithError(w, http.StatusForbidden, err.Error(), nil)
+		return principal{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+		return principal{}, false
+	}
+
+	if p.delegated() {
+		respondWithError(w, http.StatusForbidden, "this token can't be used here", nil)
+		return principal{}, false
+	}
+
+	return p, true
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/pow"
+)
+
+const (
+	powPurposeSignup = "signup"
+	powPurposeLogin  = "login"
+
+	powChallengeTTL = 5 * time.Minute
+	// signups are counted over this window to pick the difficulty
+	powSignupWindow = time.Hour
+	// and the count is only refreshed this often
+	powDifficultyTTL = time.Minute
+)
+
+// proofOfWork makes scripted signups, and logins that keep failing, pay for
+// each attempt in CPU time before we spend any on hashing a password.
+type proofOfWork struct {
+	key                []byte
+	baseDifficulty     int
+	maxDifficulty      int
+	signupsPerStep     int
+	loginAfterFailures int
+
+	mu               sync.Mutex
+	signupDifficulty int
+	computedAt       time.Time
+}
+
+func (p *proofOfWork) enabled() bool {
+	return p.baseDifficulty > 0
+}
+
+// powDifficulty returns how hard a challenge for purpose should be. Signup
+// challenges get harder as more accounts are created.
+func (cfg *apiConfig) powDifficulty(ctx context.Context, purpose string) (int, error) {
+	p := cfg.pow
+	if purpose != powPurposeSignup {
+		return p.baseDifficulty, nil
+	}
+
+	p.mu.Lock()
+	if time.Since(p.computedAt) < powDifficultyTTL {
+		difficulty := p.signupDifficulty
+		p.mu.Unlock()
+		return difficulty, nil
+	}
+	p.mu.Unlock()
+
+	// the count isn't made under the lock, so a slow query doesn't hold up
+	// every other challenge; a few requests may count at once when it expires
+	signups, err := cfg.db.CountUsersCreatedSince(ctx, time.Now().Add(-powSignupWindow))
+	if err != nil {
+		return 0, err
+	}
+	difficulty := pow.Difficulty(p.baseDifficulty, p.maxDifficulty, int(signups), p.signupsPerStep)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signupDifficulty = difficulty
+	p.computedAt = time.Now()
+	return difficulty, nil
+}
+
+func (cfg *apiConfig) handlerChallenge(w http.ResponseWriter, r *http.Request) {
+
+	type response struct {
+		Challenge  string    `json:"challenge"`
+		Algorithm  string    `json:"algorithm"`
+		Difficulty int       `json:"difficulty"`
+		ExpiresAt  time.Time `json:"expires_at"`
+	}
+
+	if !cfg.pow.enabled() {
+		respondWithError(w, http.StatusNotFound, "proof of work is not enabled", nil)
+		return
+	}
+
+	purpose := r.URL.Query().Get("purpose")
+	if purpose == "" {
+		purpose = powPurposeSignup
+	}
+	if purpose != powPurposeSignup && purpose != powPurposeLogin {
+		respondWithError(w, http.StatusBadRequest, "purpose must be signup or login", nil)
+		return
+	}
+
+	difficulty, err := cfg.powDifficulty(r.Context(), purpose)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	token, challenge, err := pow.Issue(cfg.pow.key, purpose, difficulty, powChallengeTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to make challenge", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Challenge:  token,
+		Algorithm:  "sha256",
+		Difficulty: difficulty,
+		ExpiresAt:  time.Unix(challenge.ExpiresAt, 0),
+	})
+}
+
+var errPowRequired = errors.New("proof of work required, get a challenge from /api/challenge")
+
+// checkProofOfWork verifies a solved challenge and uses it up, so each one
+// only pays for a single attempt. It writes the error response and returns
+// false if the work wasn't done.
+func (cfg *apiConfig) checkProofOfWork(w http.ResponseWriter, r *http.Request, purpose, token, solution string) bool {
+	if token == "" {
+		respondWithError(w, http.StatusForbidden, errPowRequired.Error(), nil)
+		return false
+	}
+
+	challenge, err := pow.Verify(cfg.pow.key, token, solution, purpose)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return false
+	}
+
+	rows, err := cfg.db.RedeemPowChallenge(r.Context(), database.RedeemPowChallengeParams{
+		ChallengeID: challenge.ID,
+		ExpiresAt:   time.Unix(challenge.ExpiresAt, 0),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return false
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusForbidden, "challenge has already been used", nil)
+		return false
+	}
+
+	return true
+}
+
+// loginNeedsProofOfWork reports whether the email or client IP has failed to
+// log in often enough recently that the next attempt has to be paid for.
+func (cfg *apiConfig) loginNeedsProofOfWork(ctx context.Context, keys ...string) (bool, error) {
+	if !cfg.pow.enabled() || cfg.pow.loginAfterFailures <= 0 {
+		return false, nil
+	}
+
+	throttles, err := cfg.db.GetLoginThrottles(ctx, keys)
+	if err != nil {
+		return false, err
+	}
+
+	for _, throttle := range throttles {
+		if throttle.LastFailureAt.Before(time.Now().Add(-cfg.loginThrottle.lockout)) {
+			continue
+		}
+		if int(throttle.Failures) >= cfg.pow.loginAfterFailures {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
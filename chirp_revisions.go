@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// editWindow is how long after posting a chirp its author can still change
+// it. Chirpy Red members get longer.
+func (cfg *apiConfig) editWindow(dbUser database.User) time.Duration {
+	if dbUser.IsChirpyRed {
+		return cfg.chirpEditWindowRed
+	}
+	return cfg.chirpEditWindow
+}
+
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		Body string `json:"body"`
+	}
+
+	p, ok := cfg.authorize(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "no id found", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode message", err)
+		return
+	}
+
+	if len(params.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+	if cfg.requireVerifiedEmail && !dbUser.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "email address must be verified", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	// the row stays locked until we commit, so two edits can't both save the
+	// same body as the previous revision
+	dbChirp, err := q.GetChirpForUpdate(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "no chirp found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	if dbChirp.UserID != p.UserID {
+		respondWithError(w, http.StatusForbidden, "not authorized", nil)
+		return
+	}
+
+	if time.Since(dbChirp.CreatedAt) > cfg.editWindow(dbUser) {
+		respondWithError(w, http.StatusForbidden, "chirp can no longer be edited", nil)
+		return
+	}
+
+	if params.Body == dbChirp.Body {
+		chirpResp, err := cfg.chirpToApi(r.Context(), dbChirp)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, chirpResp)
+		return
+	}
+
+	err = q.InsertChirpRevision(r.Context(), database.InsertChirpRevisionParams{
+		ChirpID:   dbChirp.ID,
+		Body:      dbChirp.Body,
+		CreatedAt: dbChirp.UpdatedAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to save revision", err)
+		return
+	}
+
+	dbChirp, err = q.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   dbChirp.ID,
+		Body: params.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update chirp", err)
+		return
+	}
+
+	// the new body may have different hashtags and mentions
+	err = q.UntagChirp(r.Context(), dbChirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update chirp", err)
+		return
+	}
+	err = tagChirp(r.Context(), q, dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update chirp", err)
+		return
+	}
+	err = q.DeleteChirpMentions(r.Context(), dbChirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update chirp", err)
+		return
+	}
+	err = mentionChirp(r.Context(), q, dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update chirp", err)
+		return
+	}
+
+	chirpResp, err := cfg.chirpToApi(r.Context(), dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpResp)
+}
+
+// handlerChirpRevisions lists the bodies a chirp had before its current one,
+// oldest first.
+func (cfg *apiConfig) handlerChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "no id found", err)
+		return
+	}
+
+	_, err = cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "no chirp found", err)
+		return
+	}
+
+	dbRevisions, err := cfg.db.ListChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	revisions := make([]ChirpRevision, len(dbRevisions))
+	for i, dbRevision := range dbRevisions {
+		revisions[i] = ChirpRevision{
+			ID:         dbRevision.ID,
+			Body:       dbRevision.Body,
+			CreatedAt:  dbRevision.CreatedAt,
+			ReplacedAt: dbRevision.ReplacedAt,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, revisions)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/entities"
+	"github.com/John-1005/Chirpy/internal/pagination"
+	"github.com/John-1005/Chirpy/internal/search"
+	"github.com/google/uuid"
+)
+
+var (
+	errInvalidAuthor = errors.New("from: must be a user id or @handle")
+	errUnknownAuthor = errors.New("no user has that handle")
+)
+
+// resolveAuthor turns the value of a from: operator, a user id or a handle
+// with or without its '@', into a user id.
+func (cfg *apiConfig) resolveAuthor(ctx context.Context, from string) (uuid.UUID, error) {
+	if id, err := uuid.Parse(from); err == nil {
+		return id, nil
+	}
+
+	handle, ok := entities.NormalizeHandle(from)
+	if !ok {
+		return uuid.Nil, errInvalidAuthor
+	}
+
+	dbUser, err := cfg.db.GetUserByHandle(ctx, handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, errUnknownAuthor
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return dbUser.ID, nil
+}
+
+// handlerSearchChirps finds chirps matching q, most relevant first, or
+// newest first with sort=recent. Pages work like handlerGetChirps, and each
+// chirp comes with a highlight: its body as HTML with the matching words in
+// <mark> tags.
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query, err := search.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if strings.TrimSpace(query.Text) == "" {
+		respondWithError(w, http.StatusBadRequest, "q must include something to search for", nil)
+		return
+	}
+
+	params := database.SearchChirpsParams{
+		Query: query.Text,
+		Since: sql.NullTime{Time: query.Since, Valid: !query.Since.IsZero()},
+		Until: sql.NullTime{Time: query.Until, Valid: !query.Until.IsZero()},
+	}
+
+	if query.From != "" {
+		authorID, err := cfg.resolveAuthor(r.Context(), query.From)
+		if errors.Is(err, errUnknownAuthor) {
+			respondWithJSON(w, http.StatusOK, []Chirps{})
+			return
+		}
+		if errors.Is(err, errInvalidAuthor) {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	limit, ok := pageSize(r, defaultChirpPageSize, maxChirpPageSize)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+		return
+	}
+	// one extra row tells us whether there is another page
+	params.MaxResults = limit + 1
+
+	var cursor *pagination.Cursor
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		decoded, err := pagination.Decode(c)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		cursor = &decoded
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	var results []database.SearchChirpsRow
+	switch r.URL.Query().Get("sort") {
+	case "", "relevance":
+		if cursor != nil {
+			params.CursorRank = sql.NullFloat64{Float64: float64(cursor.Rank), Valid: true}
+		}
+		results, err = cfg.db.SearchChirps(r.Context(), params)
+	case "recent":
+		recentParams := database.SearchChirpsRecentParams{
+			Query:      params.Query,
+			AuthorID:   params.AuthorID,
+			Since:      params.Since,
+			Until:      params.Until,
+			CursorID:   params.CursorID,
+			MaxResults: params.MaxResults,
+		}
+		if cursor != nil {
+			recentParams.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		}
+		var recent []database.SearchChirpsRecentRow
+		recent, err = cfg.db.SearchChirpsRecent(r.Context(), recentParams)
+		for _, row := range recent {
+			results = append(results, database.SearchChirpsRow(row))
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "sort must be relevance or recent", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	if len(results) > int(limit) {
+		results = results[:limit]
+		last := results[len(results)-1]
+		setNextLink(w, r, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID, Rank: last.Rank})
+	}
+
+	dbChirps := make([]database.Chirp, len(results))
+	for i, result := range results {
+		dbChirps[i] = database.Chirp{
+			ID:        result.ID,
+			UserID:    result.UserID,
+			CreatedAt: result.CreatedAt,
+			UpdatedAt: result.UpdatedAt,
+			Body:      result.Body,
+		}
+	}
+
+	apiChirps, err := cfg.chirpsToApi(r.Context(), dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	for i, result := range results {
+		apiChirps[i].Highlight = result.Highlight
+	}
+
+	respondWithJSON(w, http.StatusOK, apiChirps)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// Browsers can keep their session in cookies instead of handling tokens in
+// JavaScript. The access and refresh tokens are HttpOnly, and requests that
+// change anything also have to echo the CSRF cookie in a header, which a
+// page on another site can't read.
+const (
+	accessCookie      = "chirpy_access"
+	refreshCookie     = "chirpy_refresh"
+	csrfCookie        = auth.CSRFCookie
+	sessionModeHeader = "X-Session-Mode"
+)
+
+var errCSRF = auth.ErrCSRF
+
+// wantsCookieSession reports whether a login asked for its tokens to be set
+// as cookies rather than returned in the body.
+func (cfg *apiConfig) wantsCookieSession(r *http.Request) bool {
+	return cfg.cookieAuth && r.Header.Get(sessionModeHeader) == "cookie"
+}
+
+// usesCookieSession reports whether a request is authenticated by cookie.
+// A bearer token always takes precedence.
+func (cfg *apiConfig) usesCookieSession(r *http.Request) bool {
+	return cfg.cookieAuth && r.Header.Get("Authorization") == ""
+}
+
+func (cfg *apiConfig) setSessionCookies(w http.ResponseWriter, accessToken, refreshToken string, sessionID uuid.UUID) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessCookie,
+		Value:    accessToken,
+		Path:     "/",
+		MaxAge:   int(accessTokenTTL / time.Second),
+		HttpOnly: true,
+		Secure:   cfg.cookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refreshToken,
+		Path:     "/api",
+		MaxAge:   int(refreshTokenTTL / time.Second),
+		HttpOnly: true,
+		Secure:   cfg.cookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// the app reads this one to send it back in the CSRF header
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    cfg.csrfToken(sessionID.String()),
+		Path:     "/",
+		MaxAge:   int(refreshTokenTTL / time.Second),
+		Secure:   cfg.cookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func (cfg *apiConfig) clearSessionCookies(w http.ResponseWriter) {
+	for name, path := range map[string]string{accessCookie: "/", refreshCookie: "/api", csrfCookie: "/"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     path,
+			MaxAge:   -1,
+			Secure:   cfg.cookieSecure,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+func (cfg *apiConfig) csrfToken(sessionID string) string {
+	return auth.CSRFToken(sessionID, cfg.csrfKey)
+}
+
+// checkCSRF enforces the double-submit check on requests that change state.
+func (cfg *apiConfig) checkCSRF(r *http.Request, sessionID string) error {
+	return auth.CheckCSRF(r, sessionID, cfg.csrfKey)
+}
+
+// authenticateCookie is authenticate for cookie sessions.
+func (cfg *apiConfig) authenticateCookie(r *http.Request) (principal, error) {
+	cookie, err := r.Cookie(accessCookie)
+	if err != nil {
+		return principal{}, errors.New("no session cookie")
+	}
+
+	claims, err := cfg.keys.ParseJWT(cookie.Value)
+	if err != nil {
+		return principal{}, err
+	}
+
+	err = cfg.checkCSRF(r, claims.SessionID)
+	if err != nil {
+		return principal{}, err
+	}
+
+	return principal{
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+	}, nil
+}
+
+// refreshCookieSession is handlerRefresh for cookie sessions. The new tokens
+// are only set as cookies.
+func (cfg *apiConfig) refreshCookieSession(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(refreshCookie)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "authorization not found", err)
+		return
+	}
+
+	dbToken, err := cfg.getActiveRefreshToken(r.Context(), cookie.Value)
+	if errors.Is(err, errInvalidRefreshToken) {
+		cfg.clearSessionCookies(w)
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	err = cfg.checkCSRF(r, dbToken.FamilyID.String())
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+
+	if dbToken.ClientID.Valid {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired token", nil)
+		return
+	}
+
+	accessToken, refreshToken, err := cfg.rotateRefreshToken(r, dbToken)
+	if errors.Is(err, errInvalidRefreshToken) {
+		cfg.clearSessionCookies(w)
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to rotate refresh token", err)
+		return
+	}
+
+	cfg.setSessionCookies(w, accessToken, refreshToken, dbToken.FamilyID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeCookieSession is handlerRevoke for cookie sessions, which logs the
+// browser out.
+func (cfg *apiConfig) revokeCookieSession(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(refreshCookie)
+	if err != nil {
+		cfg.clearSessionCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	dbToken, err := cfg.db.GetRefreshToken(r.Context(), auth.HashToken(cookie.Value, cfg.secret))
+	if err != nil {
+		cfg.clearSessionCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = cfg.checkCSRF(r, dbToken.FamilyID.String())
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+
+	err = cfg.db.RevokeRefreshTokenFamily(r.Context(), dbToken.FamilyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+
+	err = cfg.revokeSessionAccessTokens(r.Context(), dbToken.FamilyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+
+	cfg.audit(r, auditSessionRevoked, dbToken.UserID, dbToken.UserID, map[string]interface{}{
+		"session_id": dbToken.FamilyID,
+	})
+
+	cfg.clearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
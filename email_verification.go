@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/mailer"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// sendEmailVerification mails a token proving ownership of the user's current
+// email address.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, dbUser database.User) error {
+	verifyToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		UserID:    dbUser.ID,
+		Email:     dbUser.Email,
+		TokenHash: auth.HashToken(verifyToken, cfg.secret),
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Use this token to verify your email address:\n\n%s\n\nIt expires in %s.",
+			verifyToken, emailVerificationTTL),
+	})
+}
+
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	verification, err := cfg.db.UseEmailVerification(r.Context(), auth.HashToken(params.Token, cfg.secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired verification token", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	// the update only matches while the user still has the address that was verified
+	dbUser, err := cfg.db.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "email address has changed since the token was sent", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to verify email", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseUserToApi(dbUser))
+}
+
+func (cfg *apiConfig) handlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+	userID := p.UserID
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+
+	if dbUser.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "email address is already verified", nil)
+		return
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), dbUser)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to send verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/entities"
+	"github.com/John-1005/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTrendingWindow = "24h"
+	defaultTrendingSize   = 10
+	maxTrendingSize       = 50
+)
+
+// trendingWindows are the periods trending can be asked for. Within one, a
+// use counts half as much for every quarter of the window that has passed
+// since, so tags taking off now beat ones that peaked earlier.
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+type TrendingHashtag struct {
+	Tag   string  `json:"tag"`
+	Uses  int64   `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+// tagChirp links a chirp to the hashtags in its body. It is run in the same
+// transaction that saves the chirp.
+func tagChirp(ctx context.Context, q *database.Queries, dbChirp database.Chirp) error {
+	tags := entities.Hashtags(dbChirp.Body)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	err := q.CreateHashtags(ctx, tags)
+	if err != nil {
+		return err
+	}
+
+	return q.TagChirp(ctx, database.TagChirpParams{
+		ChirpID:   dbChirp.ID,
+		CreatedAt: dbChirp.CreatedAt,
+		Tags:      tags,
+	})
+}
+
+// handlerHashtagChirps lists the chirps with a hashtag, newest first, a page
+// at a time like handlerGetChirps.
+func (cfg *apiConfig) handlerHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag, ok := entities.NormalizeHashtag(r.PathValue("tag"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "invalid hashtag", nil)
+		return
+	}
+
+	limit, ok := pageSize(r, defaultChirpPageSize, maxChirpPageSize)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+		return
+	}
+
+	params := database.ListHashtagChirpsParams{
+		Tag: tag,
+		// one extra row tells us whether there is another page
+		MaxResults: limit + 1,
+	}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := pagination.Decode(c)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	dbChirps, err := cfg.db.ListHashtagChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	if len(dbChirps) > int(limit) {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		setNextLink(w, r, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	apiChirps, err := cfg.chirpsToApi(r.Context(), dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiChirps)
+}
+
+// handlerTrending lists the hashtags used most over the window, 1h, 24h or
+// 7d, with recent uses weighted more.
+func (cfg *apiConfig) handlerTrending(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("window")
+	if name == "" {
+		name = defaultTrendingWindow
+	}
+	window, ok := trendingWindows[name]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "window must be 1h, 24h or 7d", nil)
+		return
+	}
+
+	limit, ok := pageSize(r, defaultTrendingSize, maxTrendingSize)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+		return
+	}
+
+	dbTrending, err := cfg.db.ListTrendingHashtags(r.Context(), database.ListTrendingHashtagsParams{
+		HalfLifeSeconds: (window / 4).Seconds(),
+		Since:           time.Now().Add(-window),
+		MaxResults:      limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	trending := make([]TrendingHashtag, len(dbTrending))
+	for i, dbTag := range dbTrending {
+		trending[i] = TrendingHashtag{
+			Tag:   dbTag.Tag,
+			Uses:  dbTag.Uses,
+			Score: dbTag.Score,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, trending)
+}
@@ -1,11 +1,14 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var testSecret = "testing-secret-token"
@@ -63,7 +66,7 @@ func TestCheckPasswordHash(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := CheckPasswordHash(tt.password, tt.hash)
+			_, err := CheckPasswordHash(tt.password, tt.hash)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CheckPasswordHash(), error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -72,6 +75,135 @@ func TestCheckPasswordHash(t *testing.T) {
 
 }
 
+func TestHashPasswordFormat(t *testing.T) {
+	hash, err := HashPassword("passwordtest123")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Errorf("Unexpected hash format: %v", hash)
+	}
+	if len(strings.Split(hash, "$")) != 6 {
+		t.Errorf("Expected 6 PHC fields, got %v", hash)
+	}
+
+	other, err := HashPassword("passwordtest123")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if hash == other {
+		t.Error("Expected different salts to give different hashes")
+	}
+}
+
+func TestNewArgon2Params(t *testing.T) {
+	tests := []struct {
+		name                            string
+		memory, iterations, parallelism int
+		wantErr                         bool
+	}{
+		{name: "Defaults", memory: 64 * 1024, iterations: 3, parallelism: 2},
+		{name: "Smallest", memory: 8, iterations: 1, parallelism: 1},
+		{name: "No parallelism", memory: 64 * 1024, iterations: 3, parallelism: 0, wantErr: true},
+		{name: "Parallelism too large", memory: 64 * 1024, iterations: 3, parallelism: 256, wantErr: true},
+		{name: "No iterations", memory: 64 * 1024, iterations: 0, parallelism: 2, wantErr: true},
+		{name: "Too little memory", memory: 15, iterations: 3, parallelism: 2, wantErr: true},
+		{name: "Negative memory", memory: -1, iterations: 3, parallelism: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewArgon2Params(tt.memory, tt.iterations, tt.parallelism)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewArgon2Params() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (p.Memory != uint32(tt.memory) || p.Parallelism != uint8(tt.parallelism) || p.KeyLength == 0) {
+				t.Errorf("Unexpected params: %+v", p)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHashNeedsRehash(t *testing.T) {
+	password := "passwordtest123"
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword failed: %v", err)
+	}
+
+	current, err := HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	SetArgon2Params(Argon2Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	weak, err := HashPassword(password)
+	SetArgon2Params(DefaultArgon2Params)
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		hash       string
+		wantRehash bool
+	}{
+		{
+			name:       "Legacy bcrypt",
+			hash:       string(bcryptHash),
+			wantRehash: true,
+		},
+		{
+			name:       "Old argon2id parameters",
+			hash:       weak,
+			wantRehash: true,
+		},
+		{
+			name:       "Current parameters",
+			hash:       current,
+			wantRehash: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsRehash, err := CheckPasswordHash(password, tt.hash)
+			if err != nil {
+				t.Fatalf("CheckPasswordHash failed: %v", err)
+			}
+			if needsRehash != tt.wantRehash {
+				t.Errorf("needsRehash = %v, want %v", needsRehash, tt.wantRehash)
+			}
+
+			needsRehash, err = CheckPasswordHash("wrongpassword", tt.hash)
+			if err == nil {
+				t.Error("Expected error for wrong password, received none")
+			}
+			if needsRehash {
+				t.Error("Expected no rehash for wrong password")
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHashMalformed(t *testing.T) {
+	hashes := []string{
+		"$argon2id$v=19$m=65536,t=3,p=2$onlysalt",
+		"$argon2id$v=18$m=65536,t=3,p=2$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=x,t=3,p=2$c2FsdA$aGFzaA",
+		"$argon2i$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA",
+		"plaintext",
+	}
+
+	for _, hash := range hashes {
+		if _, err := CheckPasswordHash("password", hash); !errors.Is(err, ErrUnknownHashFormat) {
+			t.Errorf("CheckPasswordHash(%q) error = %v, want ErrUnknownHashFormat", hash, err)
+		}
+	}
+}
+
 func TestAndValidateJWT(t *testing.T) {
 	userID := uuid.New()
 	expiresIn := time.Hour
@@ -168,3 +300,27 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken failed: %v", err)
+	}
+
+	hash := HashToken(token, testSecret)
+	if hash == token {
+		t.Fatal("Expected hash to differ from token")
+	}
+
+	if HashToken(token, testSecret) != hash {
+		t.Error("Expected hashing the same token twice to match")
+	}
+
+	if HashToken(token, "wrong-secret") == hash {
+		t.Error("Expected a different secret to produce a different hash")
+	}
+
+	if prefix := TokenPrefix(token); prefix != token[:8] {
+		t.Errorf("TokenPrefix() = %v, want %v", prefix, token[:8])
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// Cookie sessions use the double-submit pattern: the CSRF cookie is readable
+// by the app, which echoes it in a header that a page on another site can't
+// set.
+const (
+	CSRFCookie = "chirpy_csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+var ErrCSRF = errors.New("missing or invalid CSRF token")
+
+// CSRFToken is tied to the session, so a cookie planted by another site can't
+// be paired with a victim's access token.
+func CSRFToken(sessionID string, key []byte) string {
+	return HashToken(sessionID, string(key))
+}
+
+// CheckCSRF enforces the double-submit check on requests that change state.
+// The header has to match both the cookie and the token for sessionID.
+func CheckCSRF(r *http.Request, sessionID string, key []byte) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil {
+		return ErrCSRF
+	}
+	header := r.Header.Get(CSRFHeader)
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrCSRF
+	}
+	if subtle.ConstantTimeCompare([]byte(header), []byte(CSRFToken(sessionID, key))) != 1 {
+		return ErrCSRF
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCheckCSRF(t *testing.T) {
+	key := []byte("csrf-key")
+	sessionID := uuid.NewString()
+	token := CSRFToken(sessionID, key)
+	otherToken := CSRFToken(uuid.NewString(), key)
+
+	request := func(method, target, cookie, header string) *http.Request {
+		r := httptest.NewRequest(method, target, nil)
+		// the refresh and revoke paths send the refresh token cookie too
+		r.AddCookie(&http.Cookie{Name: "chirpy_refresh", Value: "refresh-token"})
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: cookie})
+		}
+		if header != "" {
+			r.Header.Set(CSRFHeader, header)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr bool
+	}{
+		{
+			name: "Safe method needs no token",
+			r:    request(http.MethodGet, "/api/sessions", "", ""),
+		},
+		{
+			name: "Refresh with the session's token",
+			r:    request(http.MethodPost, "/api/refresh", token, token),
+		},
+		{
+			name: "Revoke with the session's token",
+			r:    request(http.MethodPost, "/api/revoke", token, token),
+		},
+		{
+			name:    "Refresh from another site without the header",
+			r:       request(http.MethodPost, "/api/refresh", token, ""),
+			wantErr: true,
+		},
+		{
+			name:    "Revoke from another site without the header",
+			r:       request(http.MethodPost, "/api/revoke", token, ""),
+			wantErr: true,
+		},
+		{
+			name:    "Missing cookie",
+			r:       request(http.MethodPost, "/api/refresh", "", token),
+			wantErr: true,
+		},
+		{
+			name:    "Header doesn't match cookie",
+			r:       request(http.MethodPost, "/api/refresh", token, otherToken),
+			wantErr: true,
+		},
+		{
+			name:    "Token for another session",
+			r:       request(http.MethodPost, "/api/revoke", otherToken, otherToken),
+			wantErr: true,
+		},
+		{
+			name:    "Token made with another key",
+			r:       request(http.MethodDelete, "/api/chirps/1", CSRFToken(sessionID, []byte("other")), CSRFToken(sessionID, []byte("other"))),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCSRF(tt.r, sessionID, key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckCSRF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrCSRF) {
+				t.Errorf("Expected ErrCSRF, got %v", err)
+			}
+		})
+	}
+}
@@ -1,83 +1,166 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type CustomClaims struct {
 	UserID    uuid.UUID     `json:"user_id"`
 	ExpiresIn time.Duration `json:"expriresIn"`
+	SessionID string        `json:"sid,omitempty"`
+	Scope     string        `json:"scope,omitempty"`
+	ClientID  string        `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Argon2Params are the argon2id settings used for new password hashes.
+// Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var argon2Params = DefaultArgon2Params
+
+// NewArgon2Params checks memory, iterations and parallelism are settings
+// argon2id can run with and returns them with the default salt and key
+// lengths. argon2 needs at least 8 KiB of memory per lane.
+func NewArgon2Params(memory, iterations, parallelism int) (Argon2Params, error) {
+	if parallelism < 1 || parallelism > math.MaxUint8 {
+		return Argon2Params{}, fmt.Errorf("parallelism must be between 1 and %d, got %d", math.MaxUint8, parallelism)
+	}
+	if iterations < 1 || int64(iterations) > math.MaxUint32 {
+		return Argon2Params{}, fmt.Errorf("iterations must be between 1 and %d, got %d", uint32(math.MaxUint32), iterations)
+	}
+	if memory < 8*parallelism || int64(memory) > math.MaxUint32 {
+		return Argon2Params{}, fmt.Errorf("memory must be at least %d KiB for parallelism %d, got %d", 8*parallelism, parallelism, memory)
+	}
+
+	return Argon2Params{
+		Memory:      uint32(memory),
+		Iterations:  uint32(iterations),
+		Parallelism: uint8(parallelism),
+		SaltLength:  DefaultArgon2Params.SaltLength,
+		KeyLength:   DefaultArgon2Params.KeyLength,
+	}, nil
+}
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// SetArgon2Params changes the parameters used by HashPassword. Hashes made
+// with different parameters are reported as needing a rehash.
+func SetArgon2Params(p Argon2Params) {
+	argon2Params = p
+}
+
+// HashPassword returns an argon2id hash in PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
 func HashPassword(password string) (string, error) {
+	p := argon2Params
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	salt := make([]byte, p.SaltLength)
+	_, err := rand.Read(salt)
 	if err != nil {
 		return "", err
 	}
 
-	return string(hashedPassword), nil
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
-func CheckPasswordHash(password, hash string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	if err != nil {
-		return err
+// CheckPasswordHash compares password against an argon2id or legacy bcrypt
+// hash. needsRehash is true when the password matched but the hash should be
+// replaced with one made by HashPassword.
+func CheckPasswordHash(password, hash string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return checkArgon2(password, hash)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	default:
+		return false, ErrUnknownHashFormat
 	}
-	return nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := CustomClaims{
-		UserID:    userID,
-		ExpiresIn: expiresIn,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "Chirpy",
-			Subject:   fmt.Sprintf("%d", userID),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenString, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
+func checkArgon2(password, hash string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, ErrUnknownHashFormat
 	}
 
-	return tokenString, nil
-}
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false, ErrUnknownHashFormat
+	}
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	var p Argon2Params
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return false, ErrUnknownHashFormat
+	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	},
-		jwt.WithLeeway(0),
-		jwt.WithValidMethods([]string{"HS256"}),
-		jwt.WithExpirationRequired(),
-	)
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnknownHashFormat
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
 	if err != nil {
-		return uuid.Nil, err
+		return false, ErrUnknownHashFormat
 	}
-	claims, ok := token.Claims.(*CustomClaims)
-	if !ok || !token.Valid {
-		return uuid.Nil, fmt.Errorf("invalid token")
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(want))
+
+	got := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, errors.New("password does not match hash")
 	}
 
-	return claims.UserID, nil
+	return p != argon2Params, nil
+}
+
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return NewHMACKeyRing(tokenSecret).MakeJWT(userID, expiresIn)
+}
+
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return NewHMACKeyRing(tokenSecret).ValidateJWT(tokenString)
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -108,3 +191,25 @@ func MakeRefreshToken() (string, error) {
 	}
 	return hex.EncodeToString(b), nil
 }
+
+// HashToken returns the keyed hash that is stored in place of an opaque token
+// such as a refresh token, so a copy of the database can't be replayed.
+func HashToken(token, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TokenPrefix returns the first few characters of a token, which are safe to
+// store and log for identifying it. The fixed prefix of a personal access
+// token doesn't count towards them.
+func TokenPrefix(token string) string {
+	const prefixLength = 8
+	if IsPersonalAccessToken(token) {
+		return PersonalAccessTokenPrefix + TokenPrefix(strings.TrimPrefix(token, PersonalAccessTokenPrefix))
+	}
+	if len(token) <= prefixLength {
+		return token
+	}
+	return token[:prefixLength]
+}
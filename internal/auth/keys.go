@@ -0,0 +1,401 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Key is a single JWT key. Keys loaded from a public key only have a
+// verification half and can't sign tokens.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeyRing signs tokens with its current key and accepts tokens signed by any
+// of its keys, so old keys can keep verifying while a new one is rolled out.
+type KeyRing struct {
+	current *Key
+	keys    map[string]*Key
+
+	revocations *RevocationCache
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewHMACKey(secret string) *Key {
+	return &Key{
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// NewHMACKeyRing returns the HS256 key ring used when no asymmetric keys are
+// configured. Its tokens carry no kid header.
+func NewHMACKeyRing(secret string) *KeyRing {
+	key := NewHMACKey(secret)
+	return &KeyRing{
+		current: key,
+		keys:    map[string]*Key{key.ID: key},
+	}
+}
+
+func NewKeyRing(current *Key, others ...*Key) (*KeyRing, error) {
+	if current == nil || current.signKey == nil {
+		return nil, errors.New("current key must be able to sign")
+	}
+
+	kr := &KeyRing{
+		current: current,
+		keys:    map[string]*Key{},
+	}
+	for _, key := range append([]*Key{current}, others...) {
+		if _, ok := kr.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+	}
+
+	return kr, nil
+}
+
+// ParseKeyPEM reads an Ed25519 or RSA key in PEM form. Private keys give an
+// EdDSA or RS256 key that can sign, public keys give a verification-only key.
+func ParseKeyPEM(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.signKey = k
+		key.verifyKey = k.Public()
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.verifyKey = k
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.signKey = k
+		key.verifyKey = &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+		key.verifyKey = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// LoadKeyRing reads every .pem file in dir, using the file name without its
+// extension as the kid. The key named currentKID signs new tokens.
+func LoadKeyRing(dir, currentKID string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var current *Key
+	var others []*Key
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParseKeyPEM(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if kid == currentKID {
+			current = key
+		} else {
+			others = append(others, key)
+		}
+	}
+
+	if current == nil {
+		return nil, fmt.Errorf("signing key %q not found in %s", currentKID, dir)
+	}
+
+	return NewKeyRing(current, others...)
+}
+
+// Algorithm is the JWT alg used for newly signed tokens.
+func (kr *KeyRing) Algorithm() string {
+	return kr.current.Method.Alg()
+}
+
+// mfaAudience marks the short-lived tokens handed out between the password
+// and second factor steps of a login. They are never accepted as access tokens.
+const mfaAudience = "chirpy-mfa"
+
+// TokenOption adds optional claims to an access token.
+type TokenOption func(*CustomClaims)
+
+// WithSessionID ties an access token to the login session (refresh token
+// family) it was issued for.
+func WithSessionID(sessionID uuid.UUID) TokenOption {
+	return func(c *CustomClaims) {
+		c.SessionID = sessionID.String()
+	}
+}
+
+// WithScopes limits what an access token can be used for. Tokens without
+// scopes can do anything the user can.
+func WithScopes(scopes []string) TokenOption {
+	return func(c *CustomClaims) {
+		c.Scope = strings.Join(scopes, " ")
+	}
+}
+
+// WithClientID marks an access token as issued to an OAuth client acting on
+// the user's behalf.
+func WithClientID(clientID uuid.UUID) TokenOption {
+	return func(c *CustomClaims) {
+		c.ClientID = clientID.String()
+	}
+}
+
+// WithTokenID sets the token's jti. Tokens get a random one otherwise.
+func WithTokenID(id uuid.UUID) TokenOption {
+	return func(c *CustomClaims) {
+		c.ID = id.String()
+	}
+}
+
+// SetRevocations makes the key ring reject access tokens in revocations.
+func (kr *KeyRing) SetRevocations(revocations *RevocationCache) {
+	kr.revocations = revocations
+}
+
+func (kr *KeyRing) MakeJWT(userID uuid.UUID, expiresIn time.Duration, opts ...TokenOption) (string, error) {
+	claims := newClaims(userID, expiresIn)
+	for _, opt := range opts {
+		opt(&claims)
+	}
+	return kr.sign(claims)
+}
+
+func (kr *KeyRing) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	claims, err := kr.ParseJWT(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return claims.UserID, nil
+}
+
+// ParseJWT validates an access token issued to the user themselves and
+// returns all of its claims. Tokens issued to OAuth clients are rejected.
+func (kr *KeyRing) ParseJWT(tokenString string) (*CustomClaims, error) {
+	claims, err := kr.ParseDelegatedJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ClientID != "" {
+		return nil, errors.New("token was issued to an OAuth client")
+	}
+
+	return claims, nil
+}
+
+// ParseDelegatedJWT validates an access token that may have been issued to an
+// OAuth client. Callers have to check its scopes.
+func (kr *KeyRing) ParseDelegatedJWT(tokenString string) (*CustomClaims, error) {
+	claims, err := kr.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(claims.Audience) > 0 {
+		return nil, errors.New("token is not an access token")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("token has no id")
+	}
+
+	if kr.revocations != nil {
+		err = kr.revocations.Check(context.Background(), claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+func (kr *KeyRing) MakeMFAToken(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := newClaims(userID, expiresIn)
+	claims.Audience = jwt.ClaimStrings{mfaAudience}
+	return kr.sign(claims)
+}
+
+func (kr *KeyRing) ValidateMFAToken(tokenString string) (uuid.UUID, error) {
+	claims, err := kr.parse(tokenString, jwt.WithAudience(mfaAudience))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return claims.UserID, nil
+}
+
+func newClaims(userID uuid.UUID, expiresIn time.Duration) CustomClaims {
+	return CustomClaims{
+		UserID:    userID,
+		ExpiresIn: expiresIn,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "Chirpy",
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+	}
+}
+
+func (kr *KeyRing) sign(claims CustomClaims) (string, error) {
+	token := jwt.NewWithClaims(kr.current.Method, claims)
+	if kr.current.ID != "" {
+		token.Header["kid"] = kr.current.ID
+	}
+
+	tokenString, err := token.SignedString(kr.current.signKey)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (kr *KeyRing) parse(tokenString string, opts ...jwt.ParserOption) (*CustomClaims, error) {
+	opts = append([]jwt.ParserOption{
+		jwt.WithLeeway(0),
+		jwt.WithValidMethods(kr.methods()),
+		jwt.WithExpirationRequired(),
+	}, opts...)
+
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, kr.keyFunc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
+
+func (kr *KeyRing) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	// without this an HS256 token could be checked against a public key
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+	}
+
+	return key.verifyKey, nil
+}
+
+func (kr *KeyRing) methods() []string {
+	var methods []string
+	for _, key := range kr.keys {
+		methods = append(methods, key.Method.Alg())
+	}
+	return methods
+}
+
+// JWKS lists the public half of every asymmetric key in the ring. HMAC keys
+// are never published.
+func (kr *KeyRing) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, key := range kr.keys {
+		jwk := JWK{
+			Kid: key.ID,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+		}
+
+		switch k := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+
+	return jwks
+}
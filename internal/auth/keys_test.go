@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newEd25519PEM(t *testing.T) []byte {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func newRSAPEM(t *testing.T) []byte {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+}
+
+func mustParseKey(t *testing.T, kid string, data []byte) *Key {
+	t.Helper()
+	key, err := ParseKeyPEM(kid, data)
+	if err != nil {
+		t.Fatalf("ParseKeyPEM failed: %v", err)
+	}
+	return key
+}
+
+func TestKeyRingMakeAndValidateJWT(t *testing.T) {
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantAlg string
+	}{
+		{
+			name:    "EdDSA",
+			pem:     newEd25519PEM(t),
+			wantAlg: "EdDSA",
+		},
+		{
+			name:    "RS256",
+			pem:     newRSAPEM(t),
+			wantAlg: "RS256",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := NewKeyRing(mustParseKey(t, "key-1", tt.pem))
+			if err != nil {
+				t.Fatalf("NewKeyRing failed: %v", err)
+			}
+			if kr.Algorithm() != tt.wantAlg {
+				t.Errorf("Algorithm() = %v, want %v", kr.Algorithm(), tt.wantAlg)
+			}
+
+			userID := uuid.New()
+			token, err := kr.MakeJWT(userID, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT failed: %v", err)
+			}
+
+			validatedID, err := kr.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT failed: %v", err)
+			}
+			if validatedID != userID {
+				t.Errorf("Expected user ID: %v, got %v", userID, validatedID)
+			}
+		})
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	oldKey := mustParseKey(t, "old", newEd25519PEM(t))
+	newKey := mustParseKey(t, "new", newEd25519PEM(t))
+
+	oldRing, err := NewKeyRing(oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+	token, err := oldRing.MakeJWT(uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	rotated, err := NewKeyRing(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+	if _, err := rotated.ValidateJWT(token); err != nil {
+		t.Errorf("Expected token signed by old key to validate, got %v", err)
+	}
+
+	retired, err := NewKeyRing(newKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+	if _, err := retired.ValidateJWT(token); err == nil {
+		t.Error("Expected error once the old key is removed, received none")
+	}
+}
+
+func TestKeyRingRejectsHMACToken(t *testing.T) {
+	kr, err := NewKeyRing(mustParseKey(t, "key-1", newEd25519PEM(t)))
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+
+	token, err := MakeJWT(uuid.New(), testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	if _, err := kr.ValidateJWT(token); err == nil {
+		t.Fatal("Expected error for HS256 token, received none")
+	}
+}
+
+func TestNewKeyRingRequiresSigningKey(t *testing.T) {
+	priv := mustParseKey(t, "key-1", newEd25519PEM(t))
+	der, err := x509.MarshalPKIXPublicKey(priv.verifyKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	pub := mustParseKey(t, "key-1", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	if _, err := NewKeyRing(pub); err == nil {
+		t.Fatal("Expected error for verification-only current key, received none")
+	}
+}
+
+func TestLoadKeyRingAndJWKS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"2024-01.pem": newRSAPEM(t),
+		"2025-01.pem": newEd25519PEM(t),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	kr, err := LoadKeyRing(dir, "2025-01")
+	if err != nil {
+		t.Fatalf("LoadKeyRing failed: %v", err)
+	}
+	if kr.Algorithm() != "EdDSA" {
+		t.Errorf("Algorithm() = %v, want EdDSA", kr.Algorithm())
+	}
+
+	jwks := kr.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 keys in JWKS, got %d", len(jwks.Keys))
+	}
+	if jwks.Keys[0].Kid != "2024-01" || jwks.Keys[0].Kty != "RSA" || jwks.Keys[0].N == "" {
+		t.Errorf("Unexpected RSA JWK: %+v", jwks.Keys[0])
+	}
+	if jwks.Keys[1].Kid != "2025-01" || jwks.Keys[1].Kty != "OKP" || jwks.Keys[1].X == "" {
+		t.Errorf("Unexpected Ed25519 JWK: %+v", jwks.Keys[1])
+	}
+
+	if _, err := LoadKeyRing(dir, "missing"); err == nil {
+		t.Error("Expected error for missing signing key, received none")
+	}
+
+	if len(NewHMACKeyRing(testSecret).JWKS().Keys) != 0 {
+		t.Error("Expected HMAC keys to be left out of the JWKS")
+	}
+}
+
+func TestMFATokenIsNotAnAccessToken(t *testing.T) {
+	kr := NewHMACKeyRing(testSecret)
+	userID := uuid.New()
+
+	mfaToken, err := kr.MakeMFAToken(userID, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMFAToken failed: %v", err)
+	}
+
+	validatedID, err := kr.ValidateMFAToken(mfaToken)
+	if err != nil {
+		t.Fatalf("ValidateMFAToken failed: %v", err)
+	}
+	if validatedID != userID {
+		t.Errorf("Expected user ID: %v, got %v", userID, validatedID)
+	}
+
+	if _, err := kr.ValidateJWT(mfaToken); err == nil {
+		t.Error("Expected error using an MFA token as an access token, received none")
+	}
+
+	accessToken, err := kr.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if _, err := kr.ValidateMFAToken(accessToken); err == nil {
+		t.Error("Expected error using an access token as an MFA token, received none")
+	}
+}
+
+func TestParseJWTSessionID(t *testing.T) {
+	kr := NewHMACKeyRing(testSecret)
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	token, err := kr.MakeJWT(userID, time.Hour, WithSessionID(sessionID))
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	claims, err := kr.ParseJWT(token)
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	if claims.UserID != userID {
+		t.Errorf("Expected user ID: %v, got %v", userID, claims.UserID)
+	}
+	if claims.SessionID != sessionID.String() {
+		t.Errorf("Expected session ID: %v, got %v", sessionID, claims.SessionID)
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// from RFC 7636 appendix B
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{
+			name:      "RFC 7636 example",
+			verifier:  verifier,
+			challenge: challenge,
+			want:      true,
+		},
+		{
+			name:      "Wrong verifier",
+			verifier:  strings.Replace(verifier, "d", "e", 1),
+			challenge: challenge,
+			want:      false,
+		},
+		{
+			name:      "Plain method",
+			verifier:  verifier,
+			challenge: verifier,
+			want:      false,
+		},
+		{
+			name:      "Verifier too short",
+			verifier:  "abc",
+			challenge: challenge,
+			want:      false,
+		},
+		{
+			name:      "Verifier with invalid characters",
+			verifier:  verifier[:42] + "+",
+			challenge: challenge,
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("VerifyPKCE() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelegatedJWT(t *testing.T) {
+	kr := NewHMACKeyRing(testSecret)
+	userID := uuid.New()
+	clientID := uuid.New()
+
+	token, err := kr.MakeJWT(userID, time.Hour, WithClientID(clientID), WithScopes([]string{ScopeChirpsRead, ScopeChirpsWrite}))
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	if _, err := kr.ParseJWT(token); err == nil {
+		t.Error("Expected error parsing a client token as a first-party token, received none")
+	}
+
+	claims, err := kr.ParseDelegatedJWT(token)
+	if err != nil {
+		t.Fatalf("ParseDelegatedJWT failed: %v", err)
+	}
+	if claims.ClientID != clientID.String() {
+		t.Errorf("Expected client ID: %v, got %v", clientID, claims.ClientID)
+	}
+	if got := ParseScope(claims.Scope); strings.Join(got, ",") != "chirps:read,chirps:write" {
+		t.Errorf("Unexpected scopes: %v", got)
+	}
+}
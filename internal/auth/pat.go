@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token so they can be
+// told apart from JWTs and picked up by secret scanners.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+// Chirps are public, so nothing checks chirps:read yet. It is still granted
+// so read-only scripts and clients can ask for exactly what they need and
+// keep working if reading ever requires it.
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+	ScopeUsersWrite  = "users:write"
+)
+
+// Scopes lists every scope a personal access token can be granted.
+var Scopes = []string{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeUsersWrite,
+}
+
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// ValidateScopes checks every requested scope is known and returns them with
+// duplicates removed.
+func ValidateScopes(scopes []string) ([]string, error) {
+	seen := map[string]bool{}
+	var valid []string
+	for _, scope := range scopes {
+		if !knownScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		valid = append(valid, scope)
+	}
+
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+
+	return valid, nil
+}
+
+func knownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
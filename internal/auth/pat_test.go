@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken failed: %v", err)
+	}
+
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("Expected %v to be a personal access token", token)
+	}
+	if len(strings.TrimPrefix(token, PersonalAccessTokenPrefix)) != 64 {
+		t.Errorf("Unexpected token length: %v", token)
+	}
+
+	if got := TokenPrefix(token); got != token[:len(PersonalAccessTokenPrefix)+8] {
+		t.Errorf("TokenPrefix() = %v, want the scheme plus 8 characters", got)
+	}
+
+	refreshToken, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken failed: %v", err)
+	}
+	if IsPersonalAccessToken(refreshToken) {
+		t.Error("Expected a refresh token not to be a personal access token")
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "Known scopes",
+			scopes: []string{ScopeChirpsWrite, ScopeChirpsRead},
+			want:   []string{ScopeChirpsWrite, ScopeChirpsRead},
+		},
+		{
+			name:   "Duplicates removed",
+			scopes: []string{ScopeUsersWrite, ScopeUsersWrite},
+			want:   []string{ScopeUsersWrite},
+		},
+		{
+			name:    "Unknown scope",
+			scopes:  []string{ScopeChirpsRead, "admin"},
+			wantErr: true,
+		},
+		{
+			name:    "No scopes",
+			scopes:  nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ValidateScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the longest password bcrypt looks at. Anything after it is
+// silently ignored, so longer passwords are refused while bcrypt hashes can
+// still be around.
+const bcryptMaxBytes = 72
+
+// PasswordPolicy decides whether a new password is acceptable.
+type PasswordPolicy struct {
+	MinLength int
+	MaxBytes  int
+	Denylist  map[string]struct{}
+	Breached  *BreachedPasswords
+}
+
+// PasswordViolation is a single rule a password failed.
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength: 8,
+		MaxBytes:  bcryptMaxBytes,
+	}
+}
+
+// Check returns every rule the password breaks, or nil if it is acceptable.
+func (p PasswordPolicy) Check(password string) []PasswordViolation {
+	var violations []PasswordViolation
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    "min_length",
+			Message: fmt.Sprintf("password must be at least %d characters", p.MinLength),
+		})
+	}
+
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, PasswordViolation{
+			Rule:    "max_bytes",
+			Message: fmt.Sprintf("password must be at most %d bytes", p.MaxBytes),
+		})
+	}
+
+	if _, ok := p.Denylist[strings.ToLower(password)]; ok {
+		violations = append(violations, PasswordViolation{
+			Rule:    "denylist",
+			Message: "password is too common",
+		})
+	}
+
+	if p.Breached != nil && p.Breached.Count(password) > 0 {
+		violations = append(violations, PasswordViolation{
+			Rule:    "breached",
+			Message: "password has appeared in a data breach",
+		})
+	}
+
+	return violations
+}
+
+// LoadDenylist reads one password per line. Blank lines and lines starting
+// with # are skipped, and matching is case-insensitive.
+func LoadDenylist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	denylist := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return denylist, nil
+}
+
+// BreachedPasswords is a local copy of a breached password list keyed by
+// SHA-1, bucketed by the first five hex characters of the hash the same way
+// the Pwned Passwords range API is.
+type BreachedPasswords struct {
+	ranges map[string]map[string]int
+}
+
+// LoadBreachedPasswords reads a file of HASH:COUNT lines, where HASH is the
+// full uppercase or lowercase SHA-1 of a password in hex.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := &BreachedPasswords{ranges: map[string]map[string]int{}}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, countStr, _ := strings.Cut(line, ":")
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d: expected a SHA-1 hash", n)
+		}
+
+		count := 1
+		if countStr != "" {
+			count, err = strconv.Atoi(countStr)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+
+		hash = strings.ToUpper(hash)
+		prefix, suffix := hash[:5], hash[5:]
+		if b.ranges[prefix] == nil {
+			b.ranges[prefix] = map[string]int{}
+		}
+		b.ranges[prefix][suffix] = count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Count returns how many times the password was seen in breaches.
+func (b *BreachedPasswords) Count(password string) int {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	return b.ranges[hash[:5]][hash[5:]]
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	denylist, err := LoadDenylist(writeTestFile(t, "denylist.txt", "# common\npassword\nqwerty\n\nLetMeIn123\n"))
+	if err != nil {
+		t.Fatalf("LoadDenylist failed: %v", err)
+	}
+
+	breached, err := LoadBreachedPasswords(writeTestFile(t, "breached.txt",
+		strings.ToUpper(sha1Hex("hunter2hunter2"))+":42\n"+sha1Hex("correcthorse")+":3\n"))
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords failed: %v", err)
+	}
+
+	policy := DefaultPasswordPolicy()
+	policy.Denylist = denylist
+	policy.Breached = breached
+
+	tests := []struct {
+		name      string
+		password  string
+		wantRules []string
+	}{
+		{
+			name:      "Acceptable",
+			password:  "a perfectly fine passphrase",
+			wantRules: nil,
+		},
+		{
+			name:      "Empty",
+			password:  "",
+			wantRules: []string{"min_length"},
+		},
+		{
+			name:      "Denylisted any case",
+			password:  "letmein123",
+			wantRules: []string{"denylist"},
+		},
+		{
+			name:      "Short and denylisted",
+			password:  "qwerty",
+			wantRules: []string{"min_length", "denylist"},
+		},
+		{
+			name:      "Breached",
+			password:  "hunter2hunter2",
+			wantRules: []string{"breached"},
+		},
+		{
+			name:      "Breached lowercase hash",
+			password:  "correcthorse",
+			wantRules: []string{"breached"},
+		},
+		{
+			name:      "Too long",
+			password:  strings.Repeat("a", 73),
+			wantRules: []string{"max_bytes"},
+		},
+		{
+			name:      "Multibyte counts characters not bytes",
+			password:  "ééééééé",
+			wantRules: []string{"min_length"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := policy.Check(tt.password)
+
+			var rules []string
+			for _, v := range violations {
+				rules = append(rules, v.Rule)
+				if v.Message == "" {
+					t.Errorf("Expected a message for rule %v", v.Rule)
+				}
+			}
+			if strings.Join(rules, ",") != strings.Join(tt.wantRules, ",") {
+				t.Errorf("Check() rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestLoadBreachedPasswordsInvalid(t *testing.T) {
+	if _, err := LoadBreachedPasswords(writeTestFile(t, "breached.txt", "notahash:1\n")); err == nil {
+		t.Error("Expected error for malformed hash, received none")
+	}
+
+	if _, err := LoadBreachedPasswords(writeTestFile(t, "breached.txt", sha1Hex("x")+":many\n")); err == nil {
+		t.Error("Expected error for malformed count, received none")
+	}
+}
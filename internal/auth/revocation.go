@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrTokenRevoked is returned for an access token that was revoked before it
+// expired.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationList is every access token that was revoked before it expired.
+// Tokens are revoked one at a time by jti, or all at once for a user by
+// moving their cutoff: anything issued up to it is no longer accepted.
+// Exempt holds the jtis of tokens a user signed in for after their cutoff,
+// which iat alone can't tell apart when it falls in the same second.
+type RevocationList struct {
+	TokenIDs   map[string]struct{}
+	ValidAfter map[uuid.UUID]time.Time
+	Exempt     map[string]struct{}
+}
+
+// Revoked reports whether the list covers claims.
+func (l RevocationList) Revoked(claims *CustomClaims) bool {
+	if _, ok := l.TokenIDs[claims.ID]; ok {
+		return true
+	}
+
+	cutoff, ok := l.ValidAfter[claims.UserID]
+	if !ok {
+		return false
+	}
+	if _, ok := l.Exempt[claims.ID]; ok {
+		return false
+	}
+	return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(cutoff)
+}
+
+// RevocationCache keeps a copy of the revocation list so checking a token
+// doesn't need a database query. The list is loaded again once it is older
+// than the TTL, which bounds how long another server can keep accepting a
+// token revoked elsewhere.
+type RevocationCache struct {
+	ttl  time.Duration
+	load func(ctx context.Context) (RevocationList, error)
+
+	mu       sync.Mutex
+	list     RevocationList
+	loadedAt time.Time
+}
+
+func NewRevocationCache(ttl time.Duration, load func(ctx context.Context) (RevocationList, error)) *RevocationCache {
+	return &RevocationCache{
+		ttl:  ttl,
+		load: load,
+	}
+}
+
+// Check returns ErrTokenRevoked if claims have been revoked.
+func (c *RevocationCache) Check(ctx context.Context, claims *CustomClaims) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.loadedAt.IsZero() || time.Since(c.loadedAt) > c.ttl {
+		list, err := c.load(ctx)
+		if err != nil {
+			return err
+		}
+		c.list = list
+		c.loadedAt = time.Now()
+	}
+
+	if c.list.Revoked(claims) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Exempt keeps a token that was just issued from being caught by its user's
+// cutoff until the list is next loaded, which picks it up from the database.
+func (c *RevocationCache) Exempt(tokenID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.list.Exempt == nil {
+		c.list.Exempt = map[string]struct{}{}
+	}
+	c.list.Exempt[tokenID] = struct{}{}
+}
+
+// Invalidate makes the next check load the list again. Call it after
+// revoking tokens so this server stops accepting them straight away.
+func (c *RevocationCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestRevocationListRevoked(t *testing.T) {
+	userID := uuid.New()
+	cutoff := time.Now()
+
+	list := RevocationList{
+		TokenIDs:   map[string]struct{}{"denied": {}},
+		ValidAfter: map[uuid.UUID]time.Time{userID: cutoff},
+		Exempt:     map[string]struct{}{"fresh": {}},
+	}
+
+	claims := func(user uuid.UUID, id string, issuedAt time.Time) *CustomClaims {
+		return &CustomClaims{
+			UserID: user,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:       id,
+				IssuedAt: jwt.NewNumericDate(issuedAt),
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		claims *CustomClaims
+		want   bool
+	}{
+		{
+			name:   "Denied token id",
+			claims: claims(uuid.New(), "denied", time.Now()),
+			want:   true,
+		},
+		{
+			name:   "Issued before cutoff",
+			claims: claims(userID, "a", cutoff.Add(-time.Minute)),
+			want:   true,
+		},
+		{
+			name:   "Issued after cutoff",
+			claims: claims(userID, "b", cutoff.Add(time.Minute)),
+			want:   false,
+		},
+		{
+			name:   "Issued the same second as the cutoff",
+			claims: claims(userID, "c", cutoff),
+			want:   true,
+		},
+		{
+			name:   "Exempt token issued the same second as the cutoff",
+			claims: claims(userID, "fresh", cutoff),
+			want:   false,
+		},
+		{
+			name:   "No issued at",
+			claims: &CustomClaims{UserID: userID, RegisteredClaims: jwt.RegisteredClaims{ID: "e"}},
+			want:   true,
+		},
+		{
+			name:   "Other user",
+			claims: claims(uuid.New(), "d", cutoff.Add(-time.Minute)),
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list.Revoked(tt.claims); got != tt.want {
+				t.Errorf("Revoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevocationCacheLoads(t *testing.T) {
+	loads := 0
+	denied := map[string]struct{}{}
+	cache := NewRevocationCache(time.Hour, func(ctx context.Context) (RevocationList, error) {
+		loads++
+		list := RevocationList{TokenIDs: map[string]struct{}{}}
+		for id := range denied {
+			list.TokenIDs[id] = struct{}{}
+		}
+		return list, nil
+	})
+
+	claims := &CustomClaims{RegisteredClaims: jwt.RegisteredClaims{ID: "token"}}
+
+	for i := 0; i < 3; i++ {
+		if err := cache.Check(context.Background(), claims); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Expected the list to be loaded once, got %d", loads)
+	}
+
+	denied["token"] = struct{}{}
+	if err := cache.Check(context.Background(), claims); err != nil {
+		t.Fatalf("Expected the cached list to be used, got %v", err)
+	}
+
+	cache.Invalidate()
+	if err := cache.Check(context.Background(), claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked after Invalidate, got %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("Expected the list to be loaded twice, got %d", loads)
+	}
+}
+
+func TestRevocationCacheExempt(t *testing.T) {
+	userID := uuid.New()
+	cutoff := time.Now()
+	cache := NewRevocationCache(time.Hour, func(ctx context.Context) (RevocationList, error) {
+		return RevocationList{ValidAfter: map[uuid.UUID]time.Time{userID: cutoff}}, nil
+	})
+
+	claims := &CustomClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       "fresh",
+			IssuedAt: jwt.NewNumericDate(cutoff),
+		},
+	}
+
+	if err := cache.Check(context.Background(), claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Expected ErrTokenRevoked before Exempt, got %v", err)
+	}
+
+	cache.Exempt("fresh")
+	if err := cache.Check(context.Background(), claims); err != nil {
+		t.Errorf("Expected exempt token to pass, got %v", err)
+	}
+}
+
+func TestKeyRingRejectsRevokedTokens(t *testing.T) {
+	keys := NewHMACKeyRing("secret")
+	userID := uuid.New()
+	tokenID := uuid.New()
+
+	token, err := keys.MakeJWT(userID, time.Hour, WithTokenID(tokenID))
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	claims, err := keys.ParseJWT(token)
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	if claims.ID != tokenID.String() {
+		t.Errorf("Expected jti %v, got %v", tokenID, claims.ID)
+	}
+
+	keys.SetRevocations(NewRevocationCache(time.Hour, func(ctx context.Context) (RevocationList, error) {
+		return RevocationList{TokenIDs: map[string]struct{}{tokenID.String(): {}}}, nil
+	}))
+
+	if _, err := keys.ValidateJWT(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	other, err := keys.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if _, err := keys.ValidateJWT(other); err != nil {
+		t.Errorf("Expected other token to validate, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret from the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		name string
+		time int64
+		want string
+	}{
+		{name: "59", time: 59, want: "287082"},
+		{name: "1111111109", time: 1111111109, want: "081804"},
+		{name: "1234567890", time: 1234567890, want: "005924"},
+		{name: "2000000000", time: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.time, 0)))
+			if err != nil {
+				t.Fatalf("TOTPCode failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TOTPCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret failed: %v", err)
+	}
+	now := time.Now()
+	code, _ := TOTPCode(secret, TOTPStep(now))
+	previous, _ := TOTPCode(secret, TOTPStep(now)-1)
+	stale, _ := TOTPCode(secret, TOTPStep(now)-5)
+
+	tests := []struct {
+		name   string
+		code   string
+		wantOK bool
+	}{
+		{name: "Current code", code: code, wantOK: true},
+		{name: "Previous step", code: previous, wantOK: true},
+		{name: "Stale code", code: stale, wantOK: false},
+		{name: "Wrong length", code: "12345", wantOK: false},
+		{name: "Empty code", code: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := ValidateTOTP(secret, tt.code, now)
+			if ok != tt.wantOK {
+				t.Errorf("ValidateTOTP() = %v, want %v", ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Chirpy", "user@example.com", "ABCDEF")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:user@example.com?") {
+		t.Errorf("Unexpected URI prefix: %v", uri)
+	}
+	if !strings.Contains(uri, "secret=ABCDEF") || !strings.Contains(uri, "issuer=Chirpy") {
+		t.Errorf("Expected secret and issuer in URI: %v", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("Expected 10 codes, got %d", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if seen[code] {
+			t.Errorf("Duplicate recovery code %v", code)
+		}
+		seen[code] = true
+
+		// 16 base32 characters carry 80 bits
+		if n := len(NormalizeRecoveryCode(code)); n != 16 {
+			t.Errorf("Expected 16 characters in %v, got %d", code, n)
+		}
+	}
+
+	if got := NormalizeRecoveryCode(" A1B2-C3D4 "); got != "a1b2c3d4" {
+		t.Errorf("NormalizeRecoveryCode() = %v, want a1b2c3d4", got)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := DeriveKey(testSecret, "totp")
+	ciphertext, err := Encrypt([]byte("JBSWY3DPEHPK3PXP"), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if strings.Contains(ciphertext, "JBSWY3DPEHPK3PXP") {
+		t.Fatal("Expected ciphertext not to contain the plaintext")
+	}
+
+	plaintext, err := Decrypt(ciphertext, key)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if string(plaintext) != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Decrypt() = %v, want JBSWY3DPEHPK3PXP", string(plaintext))
+	}
+
+	if _, err := Decrypt(ciphertext, DeriveKey("other-secret", "totp")); err == nil {
+		t.Error("Expected error decrypting with the wrong key, received none")
+	}
+}
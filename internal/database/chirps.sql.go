@@ -7,6 +7,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -20,7 +22,7 @@ VALUES (
   $1,
   $2
 )
-RETURNING id, user_id, created_at, updated_at, body
+RETURNING id, user_id, created_at, updated_at, body, search
 `
 
 type AddChirpParams struct {
@@ -37,6 +39,7 @@ func (q *Queries) AddChirp(ctx context.Context, arg AddChirpParams) (Chirp, erro
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Body,
+		&i.Search,
 	)
 	return i, err
 }
@@ -57,8 +60,9 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, arg DeleteChirpByIDParams
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, user_id, created_at, updated_at, body FROM CHIRPS
-WHERE ID = $1
+SELECT chirps.id, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.body, chirps.search FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.deletion_requested_at IS NULL
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -70,17 +74,56 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Body,
+		&i.Search,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, user_id, created_at, updated_at, body FROM chirps
-ORDER BY created_at
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+SELECT id, user_id, created_at, updated_at, body, search FROM chirps
+WHERE id = $1
+FOR UPDATE
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.Search,
+	)
+	return i, err
+}
+
+const listChirps = `-- name: ListChirps :many
+SELECT chirps.id, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.body, chirps.search FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+AND ($1::uuid IS NULL OR chirps.user_id = $1)
+AND ($2::timestamp IS NULL
+  OR (chirps.created_at, chirps.id) > ($2, $3::uuid))
+ORDER BY chirps.created_at, chirps.id
+LIMIT $4
+`
+
+type ListChirpsParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	MaxResults      int32
+}
+
+func (q *Queries) ListChirps(ctx context.Context, arg ListChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirps,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +137,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Body,
+			&i.Search,
 		); err != nil {
 			return nil, err
 		}
@@ -108,13 +152,31 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsByID = `-- name: GetChirpsByID :many
-SELECT id, user_id, created_at, updated_at, body FROM CHIRPS
-WHERE user_id = $1
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT chirps.id, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.body, chirps.search FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+AND ($1::uuid IS NULL OR chirps.user_id = $1)
+AND ($2::timestamp IS NULL
+  OR (chirps.created_at, chirps.id) < ($2, $3::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsByID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByID, userID)
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	MaxResults      int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +190,89 @@ func (q *Queries) GetChirpsByID(ctx context.Context, userID uuid.UUID) ([]Chirp,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Body,
+			&i.Search,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.body, chirps.search,
+  ts_rank(chirps.search, websearch_to_tsquery('english', $1)) AS rank,
+  ts_headline('english',
+    replace(replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+    websearch_to_tsquery('english', $1),
+    'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS highlight
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.search @@ websearch_to_tsquery('english', $1)
+AND users.deletion_requested_at IS NULL
+AND ($2::uuid IS NULL OR chirps.user_id = $2)
+AND ($3::timestamp IS NULL OR chirps.created_at >= $3)
+AND ($4::timestamp IS NULL OR chirps.created_at < $4)
+AND ($5::real IS NULL
+  OR (ts_rank(chirps.search, websearch_to_tsquery('english', $1)), chirps.id)
+    < ($5, $6::uuid))
+ORDER BY rank DESC, chirps.id DESC
+LIMIT $7
+`
+
+type SearchChirpsParams struct {
+	Query      string
+	AuthorID   uuid.NullUUID
+	Since      sql.NullTime
+	Until      sql.NullTime
+	CursorRank sql.NullFloat64
+	CursorID   uuid.NullUUID
+	MaxResults int32
+}
+
+type SearchChirpsRow struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	Search    interface{}
+	Rank      float32
+	Highlight string
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorRank,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.Search,
+			&i.Rank,
+			&i.Highlight,
 		); err != nil {
 			return nil, err
 		}
@@ -141,3 +286,110 @@ func (q *Queries) GetChirpsByID(ctx context.Context, userID uuid.UUID) ([]Chirp,
 	}
 	return items, nil
 }
+
+const searchChirpsRecent = `-- name: SearchChirpsRecent :many
+SELECT chirps.id, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.body, chirps.search,
+  ts_rank(chirps.search, websearch_to_tsquery('english', $1)) AS rank,
+  ts_headline('english',
+    replace(replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+    websearch_to_tsquery('english', $1),
+    'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS highlight
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.search @@ websearch_to_tsquery('english', $1)
+AND users.deletion_requested_at IS NULL
+AND ($2::uuid IS NULL OR chirps.user_id = $2)
+AND ($3::timestamp IS NULL OR chirps.created_at >= $3)
+AND ($4::timestamp IS NULL OR chirps.created_at < $4)
+AND ($5::timestamp IS NULL
+  OR (chirps.created_at, chirps.id) < ($5, $6::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $7
+`
+
+type SearchChirpsRecentParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	MaxResults      int32
+}
+
+type SearchChirpsRecentRow struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	Search    interface{}
+	Rank      float32
+	Highlight string
+}
+
+func (q *Queries) SearchChirpsRecent(ctx context.Context, arg SearchChirpsRecentParams) ([]SearchChirpsRecentRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsRecent,
+		arg.Query,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRecentRow
+	for rows.Next() {
+		var i SearchChirpsRecentRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.Search,
+			&i.Rank,
+			&i.Highlight,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, user_id, created_at, updated_at, body, search
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.Search,
+	)
+	return i, err
+}
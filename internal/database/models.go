@@ -6,33 +6,215 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	EventType string
+	ActorID   uuid.NullUUID
+	TargetID  uuid.NullUUID
+	IpAddress string
+	UserAgent string
+	Details   json.RawMessage
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
+	Search    interface{}
 }
 
-type RefreshToken struct {
-	Token     string
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	HashtagID uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type EmailVerification struct {
+	ID        uuid.UUID
 	UserID    uuid.UUID
+	Email     string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type InviteCode struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	CreatedBy  uuid.UUID
+	CodeHash   string
+	CodePrefix string
+	MaxUses    int32
+	Uses       int32
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type InviteRedemption struct {
+	InviteID   uuid.UUID
+	UserID     uuid.UUID
+	RedeemedAt time.Time
+}
+
+type LoginThrottle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	BlockedUntil  sql.NullTime
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Type      string
+	ActorID   uuid.UUID
+	ChirpID   uuid.UUID
+	ReadAt    sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	ID            uuid.UUID
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	FamilyID      uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+}
+
+type PasswordReset struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      []string
+	ExpiresAt   sql.NullTime
+	LastUsedAt  sql.NullTime
+	RevokedAt   sql.NullTime
+}
+
+type PowRedemption struct {
+	ChallengeID string
+	ExpiresAt   time.Time
+}
+
+type RefreshToken struct {
+	ID            uuid.UUID
+	TokenHash     string
+	TokenPrefix   string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UserID        uuid.UUID
+	ExpiresAt     time.Time
+	RevokedAt     sql.NullTime
+	FamilyID      uuid.UUID
+	ReplacedBy    uuid.NullUUID
+	LastUsedAt    time.Time
+	UserAgent     string
+	IpAddress     string
+	ClientID      uuid.NullUUID
+	Scopes        []string
+	AccessTokenID uuid.NullUUID
+}
+
+type RevokedAccessToken struct {
+	TokenID   uuid.UUID
+	UserID    uuid.UUID
+	RevokedAt time.Time
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	EmailVerifiedAt     sql.NullTime
+	TotpSecret          sql.NullString
+	TotpEnabledAt       sql.NullTime
+	TotpLastStep        sql.NullInt64
+	Role                string
+	DeletionRequestedAt sql.NullTime
+	TokensValidAfter    sql.NullTime
+	Handle              sql.NullString
+}
+
+type UserIdentity struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Issuer      string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
 }
@@ -0,0 +1,158 @@
+// Package entities finds the hashtags and mentions in a chirp. A hashtag is
+// '#' followed by letters, digits and underscores, with at least one letter
+// so "#1" stays a number. A mention is '@' followed by a handle. Both have to
+// start a word, which keeps URL fragments, HTML character references like
+// &#8217; and email addresses out.
+package entities
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxHashtagLength is the longest tag we keep, not counting the '#'.
+	MaxHashtagLength = 64
+	// MaxHandleLength is the longest a handle can be, not counting the '@'.
+	MaxHandleLength = 30
+)
+
+// Mention is an @handle in a chirp. Start and End are offsets into the body
+// in Unicode code points, End exclusive, and include the '@'.
+type Mention struct {
+	Handle string
+	Start  int
+	End    int
+}
+
+// Hashtags returns the distinct hashtags in body, normalized, in the order
+// they first appear.
+func Hashtags(body string) []string {
+	var tags []string
+	seen := map[string]bool{}
+
+	prev := ' '
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		if r != '#' || !startsWord(prev) {
+			prev = r
+			i += size
+			continue
+		}
+
+		end := i + size
+		for end < len(body) {
+			next, nextSize := utf8.DecodeRuneInString(body[end:])
+			if !isTagRune(next) {
+				break
+			}
+			end += nextSize
+		}
+
+		if tag, ok := NormalizeHashtag(body[i:end]); ok && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+
+		prev, _ = utf8.DecodeLastRuneInString(body[i:end])
+		i = end
+	}
+
+	return tags
+}
+
+// NormalizeHashtag lowercases tag and strips its '#', so #Go and #go are the
+// same tag. It returns false if tag isn't a valid hashtag.
+func NormalizeHashtag(tag string) (string, bool) {
+	tag = strings.TrimPrefix(tag, "#")
+	if tag == "" || utf8.RuneCountInString(tag) > MaxHashtagLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return "", false
+		}
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+
+	return strings.ToLower(tag), true
+}
+
+// Mentions returns the mentions in body in order, with handles normalized.
+// The same handle can be mentioned more than once.
+func Mentions(body string) []Mention {
+	var mentions []Mention
+
+	prev := ' '
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != '@' || !startsWord(prev) || prev == '@' {
+			prev = r
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isTagRune(runes[end]) {
+			end++
+		}
+
+		// the whole word has to be a handle, so @bob doesn't match in @bobé
+		handle, ok := NormalizeHandle(string(runes[i+1 : end]))
+		if ok {
+			mentions = append(mentions, Mention{
+				Handle: handle,
+				Start:  i,
+				End:    end,
+			})
+		}
+
+		prev = runes[end-1]
+		i = end - 1
+	}
+
+	return mentions
+}
+
+// NormalizeHandle lowercases handle and strips its '@'. Handles are ASCII
+// letters, digits and underscores, with at least one letter. It returns false
+// if handle isn't a valid handle.
+func NormalizeHandle(handle string) (string, bool) {
+	handle = strings.TrimPrefix(handle, "@")
+	if handle == "" || len(handle) > MaxHandleLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range handle {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			hasLetter = true
+		case r >= '0' && r <= '9', r == '_':
+		default:
+			return "", false
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+
+	return strings.ToLower(handle), true
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// startsWord reports whether a '#' after prev begins a new word.
+func startsWord(prev rune) bool {
+	return !isTagRune(prev) && prev != '&' && prev != '/' && prev != '#'
+}
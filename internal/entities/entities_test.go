@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"None", "just a chirp", nil},
+		{"One", "learning #golang today", []string{"golang"}},
+		{"Start and punctuation", "#Go, (#sql) and #go!", []string{"go", "sql"}},
+		{"Underscores and digits", "#web_dev #2025goals #100", []string{"web_dev", "2025goals"}},
+		{"Unicode", "café #Crème_brûlée", []string{"crème_brûlée"}},
+		{"Inside a word", "email#tag and C#", nil},
+		{"URL fragment", "see example.com/#section", nil},
+		{"Character reference", "it&#8217;s", nil},
+		{"Back to back", "#one#two", []string{"one"}},
+		{"Too long", "#" + strings.Repeat("a", MaxHashtagLength+1), nil},
+		{"Longest", "#" + strings.Repeat("a", MaxHashtagLength), []string{strings.Repeat("a", MaxHashtagLength)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Hashtags(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Hashtags(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   string
+		wantOK bool
+	}{
+		{"#GoLang", "golang", true},
+		{"golang", "golang", true},
+		{"#", "", false},
+		{"#12", "", false},
+		{"#two words", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeHashtag(tt.tag)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeHashtag(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Mention
+	}{
+		{"None", "no one here", nil},
+		{"One", "hi @Alice!", []Mention{{"alice", 3, 9}}},
+		{"Offsets count code points", "héllo @bob", []Mention{{"bob", 6, 10}}},
+		{"Repeated", "@bob and @bob", []Mention{{"bob", 0, 4}, {"bob", 9, 13}}},
+		{"Email address", "mail bob@example.com", nil},
+		{"URL", "example.com/@bob", nil},
+		{"Doubled at", "@@bob", nil},
+		{"Non-ASCII handle", "@bobé", nil},
+		{"Digits only", "@123", nil},
+		{"Too long", "@" + strings.Repeat("a", MaxHandleLength+1), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Mentions(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Mentions(%q) = %+v, want %+v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHandle(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   string
+		wantOK bool
+	}{
+		{"@Bob_99", "bob_99", true},
+		{"bob", "bob", true},
+		{"@", "", false},
+		{"_1", "", false},
+		{"bo b", "", false},
+		{"zoë", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeHandle(tt.handle)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeHandle(%q) = %q, %v, want %q, %v", tt.handle, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer failed: %v", err)
+	}
+
+	msgs := []Message{
+		{To: "one@example.com", Subject: "First", Body: "hello"},
+		{To: "two@example.com", Subject: "Second", Body: "world"},
+	}
+	for _, msg := range msgs {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != len(msgs) {
+		t.Fatalf("Expected %d files, got %d", len(msgs), len(entries))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{"To: one@example.com", "Subject: First", "hello"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected message to contain %q, got %q", want, got)
+		}
+	}
+}
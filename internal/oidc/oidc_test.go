@@ -0,0 +1,183 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "chirpy"
+	testClientSecret = "chirpy-secret"
+	testRedirectURL  = "http://localhost:8080/api/login/oidc/callback"
+)
+
+func newTestClient(t *testing.T) (*Client, *oidctest.Server) {
+	t.Helper()
+	server := oidctest.NewServer(testClientID, testClientSecret)
+	t.Cleanup(server.Close)
+
+	client, err := Discover(context.Background(), server.Issuer(), Config{
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, server.Client())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	return client, server
+}
+
+// authorize follows the provider's authorize redirect and returns the code
+// and state it sends back.
+func authorize(t *testing.T, client *Client, state, nonce, challenge string) (string, string) {
+	t.Helper()
+	httpClient := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := httpClient.Get(client.AuthCodeURL(state, nonce, challenge))
+	if err != nil {
+		t.Fatalf("authorize request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected redirect from authorize, got %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestLoginFlow(t *testing.T) {
+	client, server := newTestClient(t)
+	server.SetUser(oidctest.User{
+		Subject:       "employee-42",
+		Email:         "employee@example.com",
+		EmailVerified: true,
+	})
+
+	verifier, err := RandomString(32)
+	if err != nil {
+		t.Fatalf("RandomString failed: %v", err)
+	}
+
+	code, state := authorize(t, client, "some-state", "some-nonce", PKCEChallenge(verifier))
+	if state != "some-state" {
+		t.Errorf("Expected state to round trip, got %v", state)
+	}
+
+	if _, err := client.Exchange(context.Background(), code, "wrong-verifier"); err == nil {
+		t.Fatal("Expected error exchanging with the wrong verifier, received none")
+	}
+
+	code, _ = authorize(t, client, "some-state", "some-nonce", PKCEChallenge(verifier))
+	token, err := client.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+
+	claims, err := client.VerifyIDToken(context.Background(), token.IDToken, "some-nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+	if claims.Subject != "employee-42" || claims.Email != "employee@example.com" || !claims.EmailVerified {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if _, err := client.VerifyIDToken(context.Background(), token.IDToken, "other-nonce"); err == nil {
+		t.Error("Expected error for mismatched nonce, received none")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	client, server := newTestClient(t)
+	other := oidctest.NewServer(testClientID, testClientSecret)
+	defer other.Close()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   server.Issuer(),
+			"sub":   "employee-42",
+			"aud":   testClientID,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{
+			name: "Wrong issuer",
+			token: func() string {
+				c := valid()
+				c["iss"] = other.Issuer()
+				return server.SignIDToken(c)
+			}(),
+		},
+		{
+			name: "Wrong audience",
+			token: func() string {
+				c := valid()
+				c["aud"] = "someone-else"
+				return server.SignIDToken(c)
+			}(),
+		},
+		{
+			name: "Expired",
+			token: func() string {
+				c := valid()
+				c["exp"] = time.Now().Add(-time.Minute).Unix()
+				return server.SignIDToken(c)
+			}(),
+		},
+		{
+			name: "No subject",
+			token: func() string {
+				c := valid()
+				delete(c, "sub")
+				return server.SignIDToken(c)
+			}(),
+		},
+		{
+			name:  "Signed by another key",
+			token: other.SignIDToken(valid()),
+		},
+	}
+
+	if _, err := client.VerifyIDToken(context.Background(), server.SignIDToken(valid()), "nonce"); err != nil {
+		t.Fatalf("Expected valid token to verify, got %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.VerifyIDToken(context.Background(), tt.token, "nonce"); err == nil {
+				t.Error("Expected error, received none")
+			}
+		})
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	server := oidctest.NewServer(testClientID, testClientSecret)
+	defer server.Close()
+
+	_, err := Discover(context.Background(), server.Issuer()+"/", Config{ClientID: testClientID}, server.Client())
+	if err == nil {
+		t.Fatal("Expected error for mismatched issuer, received none")
+	}
+}
@@ -0,0 +1,47 @@
+// Package pagination encodes the cursors used for keyset pagination. A
+// cursor is the sort key of the last row on a page, so the next page starts
+// right after it however many rows were added or removed in the meantime.
+// Clients should treat cursors as opaque.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a row by (created_at, id). The id breaks ties between
+// rows created at the same instant. Results ordered by relevance also carry
+// the row's rank.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Rank      float32   `json:"r,omitempty"`
+}
+
+// Encode returns the cursor as a URL safe string.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a cursor made by Encode.
+func Decode(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	err = json.Unmarshal(data, &c)
+	if err != nil || c.CreatedAt.IsZero() || c.ID == uuid.Nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
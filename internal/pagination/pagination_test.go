@@ -0,0 +1,48 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	c := Cursor{
+		CreatedAt: time.Date(2025, 3, 4, 5, 6, 7, 123456000, time.UTC),
+		ID:        uuid.New(),
+		Rank:      0.0607927,
+	}
+
+	got, err := Decode(c.Encode())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID || got.Rank != c.Rank {
+		t.Errorf("Expected %+v, got %+v", c, got)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"Empty", ""},
+		{"Not base64", "not a cursor!"},
+		{"Not JSON", base64.RawURLEncoding.EncodeToString([]byte("nope"))},
+		{"Missing time", base64.RawURLEncoding.EncodeToString([]byte(`{"id":"` + uuid.NewString() + `"}`))},
+		{"Missing id", base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2025-03-04T05:06:07Z"}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.cursor)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Expected %v, got %v", ErrInvalidCursor, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Package pow issues and checks hashcash-style proof-of-work puzzles. A
+// challenge is signed rather than stored, so handing one out costs the
+// server nothing. Solving it means finding a string whose SHA-256 hash,
+// appended to the challenge, starts with a given number of zero bits.
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSolutionLength keeps solutions short, so checking one is always cheap.
+const MaxSolutionLength = 64
+
+var (
+	ErrInvalidChallenge = errors.New("invalid challenge")
+	ErrExpired          = errors.New("challenge has expired")
+	ErrWrongPurpose     = errors.New("challenge was issued for something else")
+	ErrInsufficientWork = errors.New("solution does not meet the difficulty")
+)
+
+// Challenge is what a signed challenge token carries.
+type Challenge struct {
+	ID         string `json:"id"`
+	Purpose    string `json:"purpose"`
+	Difficulty int    `json:"difficulty"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+// Issue makes a challenge for purpose that needs difficulty leading zero
+// bits and is valid for ttl.
+func Issue(key []byte, purpose string, difficulty int, ttl time.Duration) (string, Challenge, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", Challenge{}, err
+	}
+
+	challenge := Challenge{
+		ID:         base64.RawURLEncoding.EncodeToString(id),
+		Purpose:    purpose,
+		Difficulty: difficulty,
+		ExpiresAt:  time.Now().Add(ttl).Unix(),
+	}
+
+	payload, err := json.Marshal(challenge)
+	if err != nil {
+		return "", Challenge{}, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(key, encoded), challenge, nil
+}
+
+// Verify checks token was issued by us for purpose and hasn't expired, and
+// that solution does the work it asks for. Callers still have to make sure
+// each challenge is only redeemed once.
+func Verify(key []byte, token, solution, purpose string) (Challenge, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(key, encoded))) {
+		return Challenge{}, ErrInvalidChallenge
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Challenge{}, ErrInvalidChallenge
+	}
+
+	var challenge Challenge
+	err = json.Unmarshal(payload, &challenge)
+	if err != nil {
+		return Challenge{}, ErrInvalidChallenge
+	}
+
+	if challenge.Purpose != purpose {
+		return Challenge{}, ErrWrongPurpose
+	}
+	if time.Now().Unix() > challenge.ExpiresAt {
+		return Challenge{}, ErrExpired
+	}
+
+	if solution == "" || len(solution) > MaxSolutionLength || LeadingZeroBits(hash(token, solution)) < challenge.Difficulty {
+		return Challenge{}, ErrInsufficientWork
+	}
+
+	return challenge, nil
+}
+
+// Solve finds a solution to token by brute force. It is what a client has to
+// do, and is here for tests and command line clients.
+func Solve(token string, difficulty int) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		if LeadingZeroBits(hash(token, solution)) >= difficulty {
+			return solution
+		}
+	}
+}
+
+// LeadingZeroBits counts the zero bits at the start of sum.
+func LeadingZeroBits(sum []byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Difficulty scales base up by one bit, doubling the expected work, every
+// time recent activity doubles past perStep, up to max.
+func Difficulty(base, max, recent, perStep int) int {
+	if perStep <= 0 || recent <= perStep {
+		return base
+	}
+
+	difficulty := base + int(math.Log2(float64(recent)/float64(perStep)))
+	if difficulty > max {
+		return max
+	}
+	return difficulty
+}
+
+func hash(token, solution string) []byte {
+	sum := sha256.Sum256([]byte(token + solution))
+	return sum[:]
+}
+
+func sign(key []byte, data string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package pow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("test-key")
+
+func TestIssueAndVerify(t *testing.T) {
+	token, issued, err := Issue(testKey, "signup", 8, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	solution := Solve(token, 8)
+	challenge, err := Verify(testKey, token, solution, "signup")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if challenge != issued {
+		t.Errorf("Expected %+v, got %+v", issued, challenge)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	token, _, err := Issue(testKey, "signup", 8, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	solution := Solve(token, 8)
+
+	expired, _, err := Issue(testKey, "signup", 8, -time.Minute)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	// a harder challenge where the easy solution almost certainly falls short
+	hard, _, err := Issue(testKey, "signup", 32, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name     string
+		key      []byte
+		token    string
+		solution string
+		purpose  string
+		wantErr  error
+	}{
+		{
+			name:     "Wrong key",
+			key:      []byte("other-key"),
+			token:    token,
+			solution: solution,
+			purpose:  "signup",
+			wantErr:  ErrInvalidChallenge,
+		},
+		{
+			name:     "Tampered payload",
+			key:      testKey,
+			token:    payload + "x." + signature,
+			solution: solution,
+			purpose:  "signup",
+			wantErr:  ErrInvalidChallenge,
+		},
+		{
+			name:     "Wrong purpose",
+			key:      testKey,
+			token:    token,
+			solution: solution,
+			purpose:  "login",
+			wantErr:  ErrWrongPurpose,
+		},
+		{
+			name:     "Expired",
+			key:      testKey,
+			token:    expired,
+			solution: Solve(expired, 8),
+			purpose:  "signup",
+			wantErr:  ErrExpired,
+		},
+		{
+			name:     "Not enough work",
+			key:      testKey,
+			token:    hard,
+			solution: "0",
+			purpose:  "signup",
+			wantErr:  ErrInsufficientWork,
+		},
+		{
+			name:     "Empty solution",
+			key:      testKey,
+			token:    token,
+			solution: "",
+			purpose:  "signup",
+			wantErr:  ErrInsufficientWork,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(tt.key, tt.token, tt.solution, tt.purpose)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		sum  []byte
+		want int
+	}{
+		{[]byte{0xff}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x80}, 8},
+		{[]byte{0x00, 0x00, 0x10}, 19},
+		{[]byte{0x00, 0x00}, 16},
+	}
+
+	for _, tt := range tests {
+		if got := LeadingZeroBits(tt.sum); got != tt.want {
+			t.Errorf("LeadingZeroBits(%x) = %d, want %d", tt.sum, got, tt.want)
+		}
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	tests := []struct {
+		name   string
+		recent int
+		want   int
+	}{
+		{"Quiet", 5, 16},
+		{"At the threshold", 20, 16},
+		{"Double", 40, 17},
+		{"Eight times", 160, 19},
+		{"Capped", 100000, 22},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Difficulty(16, 22, tt.recent, 20); got != tt.want {
+				t.Errorf("Difficulty() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
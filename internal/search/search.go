@@ -0,0 +1,111 @@
+// Package search parses the query language of chirp search. Everything that
+// isn't an operator is left for Postgres' websearch_to_tsquery, which already
+// understands "quoted phrases", -negation and OR. The operators are:
+//
+//	from:<user>       only chirps by that user
+//	since:YYYY-MM-DD  posted on or after that day
+//	until:YYYY-MM-DD  posted on or before that day
+//
+// Operators inside quotes are searched for like any other text.
+package search
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrEmptyOperator = errors.New("search operator is missing a value")
+	ErrInvalidDate   = errors.New("dates must look like 2006-01-02")
+)
+
+// Query is a parsed search. Until is exclusive, so it is the start of the day
+// after the one asked for. Zero times mean no limit.
+type Query struct {
+	Text  string
+	From  string
+	Since time.Time
+	Until time.Time
+}
+
+// Parse splits the operators out of q.
+func Parse(q string) (Query, error) {
+	var query Query
+	var text []string
+
+	for _, term := range split(q) {
+		name, value, ok := strings.Cut(term, ":")
+		if !ok || strings.HasPrefix(term, `"`) {
+			text = append(text, term)
+			continue
+		}
+
+		switch strings.ToLower(name) {
+		case "from":
+			if value == "" {
+				return Query{}, ErrEmptyOperator
+			}
+			query.From = value
+		case "since":
+			day, err := parseDate(value)
+			if err != nil {
+				return Query{}, err
+			}
+			query.Since = day
+		case "until":
+			day, err := parseDate(value)
+			if err != nil {
+				return Query{}, err
+			}
+			query.Until = day.AddDate(0, 0, 1)
+		default:
+			text = append(text, term)
+		}
+	}
+
+	query.Text = strings.Join(text, " ")
+	return query, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, ErrEmptyOperator
+	}
+	day, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+	return day, nil
+}
+
+// split breaks q on whitespace, keeping quoted phrases together with their
+// quotes.
+func split(q string) []string {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			term.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+
+	return terms
+}
@@ -0,0 +1,89 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(dateLayout, s)
+		return d
+	}
+
+	tests := []struct {
+		name string
+		q    string
+		want Query
+	}{
+		{
+			name: "Plain words",
+			q:    "hello  world",
+			want: Query{Text: "hello world"},
+		},
+		{
+			name: "Phrase and negation",
+			q:    `"good morning" -coffee`,
+			want: Query{Text: `"good morning" -coffee`},
+		},
+		{
+			name: "Operators",
+			q:    "kitten From:abc since:2025-01-01 until:2025-01-31",
+			want: Query{
+				Text:  "kitten",
+				From:  "abc",
+				Since: day("2025-01-01"),
+				Until: day("2025-02-01"),
+			},
+		},
+		{
+			name: "Operator inside a phrase",
+			q:    `"from:abc is here" x`,
+			want: Query{Text: `"from:abc is here" x`},
+		},
+		{
+			name: "Unknown operator is text",
+			q:    "http://example.com",
+			want: Query{Text: "http://example.com"},
+		},
+		{
+			name: "Only operators",
+			q:    "from:abc",
+			want: Query{From: "abc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.q)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       string
+		wantErr error
+	}{
+		{"Empty from", "cats from:", ErrEmptyOperator},
+		{"Empty since", "since:", ErrEmptyOperator},
+		{"Bad date", "until:yesterday", ErrInvalidDate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.q)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
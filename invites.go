@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type registrationMode string
+
+const (
+	registrationOpen   registrationMode = "open"
+	registrationInvite registrationMode = "invite"
+	registrationClosed registrationMode = "closed"
+)
+
+func (m registrationMode) valid() bool {
+	switch m {
+	case registrationOpen, registrationInvite, registrationClosed:
+		return true
+	}
+	return false
+}
+
+var (
+	errRegistrationClosed = errors.New("registration is closed")
+	errInviteRequired     = errors.New("an invite code is required")
+	errInvalidInvite      = errors.New("invalid or expired invite code")
+)
+
+type Invite struct {
+	ID          uuid.UUID          `json:"id"`
+	Prefix      string             `json:"prefix"`
+	MaxUses     int32              `json:"max_uses"`
+	Uses        int32              `json:"uses"`
+	CreatedAt   time.Time          `json:"created_at"`
+	ExpiresAt   *time.Time         `json:"expires_at"`
+	RevokedAt   *time.Time         `json:"revoked_at"`
+	Redemptions []InviteRedemption `json:"redemptions"`
+	Code        string             `json:"code,omitempty"`
+}
+
+type InviteRedemption struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// registerUser creates an account if the registration mode allows it. In
+// invite mode a use of the invite is taken in the same transaction, so a
+// failed signup doesn't spend it.
+func (cfg *apiConfig) registerUser(ctx context.Context, params database.CreateUserParams, inviteCode string) (database.User, error) {
+	switch cfg.registrationMode {
+	case registrationClosed:
+		return database.User{}, errRegistrationClosed
+	case registrationInvite:
+		if inviteCode == "" {
+			return database.User{}, errInviteRequired
+		}
+	default:
+		return cfg.db.CreateUser(ctx, params)
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	invite, err := q.RedeemInviteCode(ctx, auth.HashToken(inviteCode, cfg.secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errInvalidInvite
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	dbUser, err := q.CreateUser(ctx, params)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = q.CreateInviteRedemption(ctx, database.CreateInviteRedemptionParams{
+		InviteID: invite.ID,
+		UserID:   dbUser.ID,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return dbUser, tx.Commit()
+}
+
+// inviteLimit is how many unused invitations a user may have out at once.
+// Admins aren't limited.
+func (cfg *apiConfig) inviteLimit(dbUser database.User) (int, bool) {
+	if hasRole(dbUser, roleAdmin) {
+		return 0, false
+	}
+	if dbUser.IsChirpyRed {
+		return cfg.inviteQuotaRed, true
+	}
+	return cfg.inviteQuota, true
+}
+
+func (cfg *apiConfig) handlerCreateInvite(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		MaxUses   int32      `json:"max_uses"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	if params.MaxUses == 0 {
+		params.MaxUses = 1
+	}
+	if params.MaxUses < 0 {
+		respondWithError(w, http.StatusBadRequest, "max_uses must be positive", nil)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if params.ExpiresAt.Before(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "expires_at must be in the future", nil)
+			return
+		}
+		expiresAt = sql.NullTime{Time: *params.ExpiresAt, Valid: true}
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+
+	if quota, limited := cfg.inviteLimit(dbUser); limited {
+		outstanding, err := cfg.db.CountOutstandingInvites(r.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+			return
+		}
+		if int(outstanding)+int(params.MaxUses) > quota {
+			respondWithError(w, http.StatusForbidden, "invite quota exceeded", nil)
+			return
+		}
+	}
+
+	code := rand.Text()
+
+	dbInvite, err := cfg.db.CreateInviteCode(r.Context(), database.CreateInviteCodeParams{
+		CreatedBy:  dbUser.ID,
+		CodeHash:   auth.HashToken(code, cfg.secret),
+		CodePrefix: auth.TokenPrefix(code),
+		MaxUses:    params.MaxUses,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to save invite", err)
+		return
+	}
+
+	// like tokens, only the hash of the code is kept
+	resp := databaseInviteToApi(dbInvite)
+	resp.Code = code
+
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerListInvites(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	dbInvites, err := cfg.db.ListInviteCodes(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	dbRedemptions, err := cfg.db.ListInviteRedemptions(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	redemptions := map[uuid.UUID][]InviteRedemption{}
+	for _, dbRedemption := range dbRedemptions {
+		redemptions[dbRedemption.InviteID] = append(redemptions[dbRedemption.InviteID], InviteRedemption{
+			UserID:     dbRedemption.UserID,
+			Email:      dbRedemption.Email,
+			RedeemedAt: dbRedemption.RedeemedAt,
+		})
+	}
+
+	invites := make([]Invite, len(dbInvites))
+	for i, dbInvite := range dbInvites {
+		invites[i] = databaseInviteToApi(dbInvite)
+		if found, ok := redemptions[dbInvite.ID]; ok {
+			invites[i].Redemptions = found
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, invites)
+}
+
+func (cfg *apiConfig) handlerRevokeInvite(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	inviteID, err := uuid.Parse(r.PathValue("inviteID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid invite id", err)
+		return
+	}
+
+	dbInvite, err := cfg.db.GetInviteCode(r.Context(), inviteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "invite not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	if dbInvite.CreatedBy != p.UserID {
+		// admins can revoke anyone's invites
+		dbUser, err := cfg.db.GetUserByID(r.Context(), p.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "user not found", err)
+			return
+		}
+		if !hasRole(dbUser, roleAdmin) {
+			respondWithError(w, http.StatusNotFound, "invite not found", nil)
+			return
+		}
+	}
+
+	err = cfg.db.RevokeInviteCode(r.Context(), dbInvite.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke invite", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func databaseInviteToApi(dbInvite database.InviteCode) Invite {
+	invite := Invite{
+		ID:          dbInvite.ID,
+		Prefix:      dbInvite.CodePrefix,
+		MaxUses:     dbInvite.MaxUses,
+		Uses:        dbInvite.Uses,
+		CreatedAt:   dbInvite.CreatedAt,
+		Redemptions: []InviteRedemption{},
+	}
+	if dbInvite.ExpiresAt.Valid {
+		invite.ExpiresAt = &dbInvite.ExpiresAt.Time
+	}
+	if dbInvite.RevokedAt.Valid {
+		invite.RevokedAt = &dbInvite.RevokedAt.Time
+	}
+	return invite
+}
@@ -18,7 +18,7 @@ func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
   }
 
   type errorResponse struct {
-    Error string `json: error`
+    Error string `json:"Error"`
   }
 
   respondWithJSON(w, code, errorResponse{
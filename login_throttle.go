@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/database"
+)
+
+// loginThrottle slows down password guessing. Failures are counted per
+// account and per client IP in Postgres so every server instance sees them.
+type loginThrottle struct {
+	maxFailures   int
+	maxIPFailures int
+	lockout       time.Duration
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+}
+
+func emailThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// blockFor returns how long a key stays blocked after its nth consecutive
+// failure. The delay doubles with every failure until limit is reached, at
+// which point the key is locked out.
+func (t loginThrottle) blockFor(failures, limit int) (time.Duration, bool) {
+	if failures >= limit {
+		return t.lockout, true
+	}
+
+	delay := t.baseDelay * time.Duration(math.Pow(2, float64(failures-1)))
+	if delay > t.maxDelay {
+		delay = t.maxDelay
+	}
+	return delay, false
+}
+
+// loginRetryAfter returns how long the caller has to wait before it may try
+// to log in again, or zero if it isn't blocked.
+func (cfg *apiConfig) loginRetryAfter(ctx context.Context, keys ...string) (time.Duration, error) {
+	throttles, err := cfg.db.GetLoginThrottles(ctx, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if !throttle.BlockedUntil.Valid {
+			continue
+		}
+		if remaining := time.Until(throttle.BlockedUntil.Time); remaining > wait {
+			wait = remaining
+		}
+	}
+
+	return wait, nil
+}
+
+// reserveLoginAttempt counts an attempt as a failure against both keys before
+// the password or code is checked, so a burst of parallel guesses can't all
+// get in before the first failure is recorded. If either key is still blocked
+// nothing is reserved and it returns how long to wait. An attempt that
+// succeeds hands its reservation back with releaseLoginAttempt.
+func (cfg *apiConfig) reserveLoginAttempt(ctx context.Context, emailKey, ipKey string) (time.Duration, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	now := time.Now().UTC()
+	limits := map[string]int{
+		emailKey: cfg.loginThrottle.maxFailures,
+		ipKey:    cfg.loginThrottle.maxIPFailures,
+	}
+
+	// the rows stay locked until we commit, and are always locked email key
+	// first, so two attempts can't deadlock or both see the old count
+	var throttles []database.LoginThrottle
+	var wait time.Duration
+	for _, key := range []string{emailKey, ipKey} {
+		err := q.EnsureLoginThrottle(ctx, database.EnsureLoginThrottleParams{
+			Key:           key,
+			LastFailureAt: now,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		throttle, err := q.GetLoginThrottleForUpdate(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if throttle.BlockedUntil.Valid && throttle.BlockedUntil.Time.Sub(now) > wait {
+			wait = throttle.BlockedUntil.Time.Sub(now)
+		}
+		throttles = append(throttles, throttle)
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	for _, throttle := range throttles {
+		failures := int(throttle.Failures)
+		if throttle.LastFailureAt.Before(now.Add(-cfg.loginThrottle.lockout)) {
+			failures = 0
+		}
+		failures++
+
+		delay, locked := cfg.loginThrottle.blockFor(failures, limits[throttle.Key])
+		err := q.UpdateLoginThrottle(ctx, database.UpdateLoginThrottleParams{
+			Key:           throttle.Key,
+			Failures:      int32(failures),
+			LastFailureAt: now,
+			BlockedUntil:  sql.NullTime{Time: now.Add(delay), Valid: true},
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if locked && failures == limits[throttle.Key] {
+			log.Printf("login lockout: %s locked for %s after %d failed attempts", throttle.Key, delay, failures)
+		}
+	}
+
+	return 0, tx.Commit()
+}
+
+// releaseLoginAttempt takes back the failure reserveLoginAttempt counted once
+// the attempt has succeeded. The block it set is lifted too, unless the key
+// is locked out by the failures before it. Failing to release is logged and
+// leaves the attempt counted.
+func (cfg *apiConfig) releaseLoginAttempt(ctx context.Context, emailKey, ipKey string) {
+	err := cfg.releaseLoginKeys(ctx, emailKey, ipKey)
+	if err != nil {
+		log.Printf("failed to release login attempt for %s: %s", emailKey, err)
+	}
+}
+
+func (cfg *apiConfig) releaseLoginKeys(ctx context.Context, emailKey, ipKey string) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	limits := map[string]int{
+		emailKey: cfg.loginThrottle.maxFailures,
+		ipKey:    cfg.loginThrottle.maxIPFailures,
+	}
+	for _, key := range []string{emailKey, ipKey} {
+		throttle, err := q.GetLoginThrottleForUpdate(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		failures := int(throttle.Failures) - 1
+		if failures <= 0 {
+			err = q.ClearLoginThrottle(ctx, key)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		blockedUntil := sql.NullTime{}
+		if failures >= limits[key] {
+			blockedUntil = throttle.BlockedUntil
+		}
+		err = q.UpdateLoginThrottle(ctx, database.UpdateLoginThrottleParams{
+			Key:           key,
+			Failures:      int32(failures),
+			LastFailureAt: throttle.LastFailureAt,
+			BlockedUntil:  blockedUntil,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, emailKey string) {
+	err := cfg.db.ClearLoginThrottle(ctx, emailKey)
+	if err != nil {
+		log.Printf("failed to clear login failures for %s: %s", emailKey, err)
+	}
+}
+
+func respondTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "too many failed login attempts, try again later", nil)
+}
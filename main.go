@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -8,13 +9,16 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/John-1005/Chirpy/internal/auth"
 	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/mailer"
+	"github.com/John-1005/Chirpy/internal/oidc"
+	"github.com/John-1005/Chirpy/internal/pagination"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -23,27 +27,65 @@ import (
 type apiConfig struct {
 	fileServerHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
 	platform       string
 	secret         string
+	keys           *auth.KeyRing
 	polka_key      string
+	mailer         mailer.Mailer
+
+	requireVerifiedEmail bool
+	totpKey              []byte
+	trustProxy           bool
+	loginThrottle        loginThrottle
+	passwordPolicy       auth.PasswordPolicy
+	oidc                 *oidc.Client
+	oidcStateKey         []byte
+	accountDeletionGrace time.Duration
+	revocations          *auth.RevocationCache
+	cookieAuth           bool
+	cookieSecure         bool
+	csrfKey              []byte
+	registrationMode     registrationMode
+	inviteQuota          int
+	inviteQuotaRed       int
+	pow                  *proofOfWork
+	chirpEditWindow      time.Duration
+	chirpEditWindowRed   time.Duration
 }
 
+const (
+	accessTokenTTL  = 1 * time.Hour
+	refreshTokenTTL = 60 * 24 * time.Hour
+	maxChirpLength  = 140
+
+	defaultChirpPageSize = 50
+	maxChirpPageSize     = 100
+)
+
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
+	TOTPEnabled   bool      `json:"totp_enabled"`
+	Role          string    `json:"role"`
+	Handle        string    `json:"handle"`
 }
 
 type Chirps struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	User_ID   uuid.UUID `json:"user_id"`
+	ID        uuid.UUID      `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Body      string         `json:"body"`
+	User_ID   uuid.UUID      `json:"user_id"`
+	Edited    bool           `json:"edited"`
+	Mentions  []ChirpMention `json:"mentions"`
+	Highlight string         `json:"highlight,omitempty"`
 }
 
 func main() {
@@ -69,36 +111,202 @@ func main() {
 		log.Fatal("polka key must be set")
 	}
 
+	keys := auth.NewHMACKeyRing(secret)
+	jwtAlg := os.Getenv("JWT_ALG")
+	if jwtAlg != "" && jwtAlg != "HS256" {
+		keys, err = auth.LoadKeyRing(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_SIGNING_KID"))
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %s", err)
+		}
+		if keys.Algorithm() != jwtAlg {
+			log.Fatalf("JWT signing key uses %s, expected %s", keys.Algorithm(), jwtAlg)
+		}
+	}
+
+	argon2Params, err := auth.NewArgon2Params(
+		envInt("PASSWORD_ARGON2_MEMORY", int(auth.DefaultArgon2Params.Memory)),
+		envInt("PASSWORD_ARGON2_ITERATIONS", int(auth.DefaultArgon2Params.Iterations)),
+		envInt("PASSWORD_ARGON2_PARALLELISM", int(auth.DefaultArgon2Params.Parallelism)),
+	)
+	if err != nil {
+		log.Fatalf("Invalid PASSWORD_ARGON2 settings: %s", err)
+	}
+	auth.SetArgon2Params(argon2Params)
+
+	passwordPolicy := auth.DefaultPasswordPolicy()
+	passwordPolicy.MinLength = envInt("PASSWORD_MIN_LENGTH", passwordPolicy.MinLength)
+	if path := os.Getenv("PASSWORD_DENYLIST_FILE"); path != "" {
+		passwordPolicy.Denylist, err = auth.LoadDenylist(path)
+		if err != nil {
+			log.Fatalf("Error loading password denylist: %s", err)
+		}
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		passwordPolicy.Breached, err = auth.LoadBreachedPasswords(path)
+		if err != nil {
+			log.Fatalf("Error loading breached passwords: %s", err)
+		}
+	}
+
+	var mail mailer.Mailer = mailer.LogMailer{}
+	if os.Getenv("MAILER") == "file" {
+		mailDir := os.Getenv("MAIL_DIR")
+		if mailDir == "" {
+			mailDir = "mail"
+		}
+		mail, err = mailer.NewFileMailer(mailDir)
+		if err != nil {
+			log.Fatalf("Error creating mail directory: %s", err)
+		}
+	}
+
+	var oidcClient *oidc.Client
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		oidcClient, err = oidc.Discover(context.Background(), issuer, oidc.Config{
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		}, nil)
+		if err != nil {
+			log.Fatalf("Error loading OIDC provider: %s", err)
+		}
+	}
+
 	dbQueries := database.New(dbConn)
 
+	// the first admin is named in the environment, after that admins can
+	// promote other users themselves
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		rows, err := dbQueries.SetUserRoleByEmail(context.Background(), database.SetUserRoleByEmailParams{
+			Email: adminEmail,
+			Role:  roleAdmin,
+		})
+		if err != nil {
+			log.Fatalf("Error promoting %s to admin: %s", adminEmail, err)
+		}
+		if rows == 0 {
+			log.Printf("ADMIN_EMAIL %s has no account yet, restart after it signs up", adminEmail)
+		}
+	}
+
+	registration := registrationMode(os.Getenv("REGISTRATION_MODE"))
+	if registration == "" {
+		registration = registrationOpen
+	}
+	if !registration.valid() {
+		log.Fatalf("REGISTRATION_MODE must be open, invite or closed, got %q", registration)
+	}
+
 	apiCfg := &apiConfig{
 		fileServerHits: atomic.Int32{},
 		db:             dbQueries,
+		dbConn:         dbConn,
 		platform:       os.Getenv("PLATFORM"),
 		secret:         os.Getenv("SECRET"),
+		keys:           keys,
 		polka_key:      os.Getenv("POLKA_KEY"),
-	}
+		mailer:         mail,
+
+		requireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		totpKey:              auth.DeriveKey(secret, "totp"),
+		trustProxy:           os.Getenv("TRUST_PROXY") == "true",
+		loginThrottle: loginThrottle{
+			maxFailures:   envInt("LOGIN_MAX_FAILURES", 5),
+			maxIPFailures: envInt("LOGIN_MAX_IP_FAILURES", 50),
+			lockout:       envDuration("LOGIN_LOCKOUT", 15*time.Minute),
+			baseDelay:     1 * time.Second,
+			maxDelay:      1 * time.Minute,
+		},
+		passwordPolicy: passwordPolicy,
+		oidc:           oidcClient,
+		oidcStateKey:   auth.DeriveKey(secret, "oidc-state"),
+
+		accountDeletionGrace: envDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+		cookieAuth:           os.Getenv("COOKIE_AUTH") == "true",
+		cookieSecure:         os.Getenv("COOKIE_SECURE") != "false",
+		csrfKey:              auth.DeriveKey(secret, "csrf"),
+		registrationMode:     registration,
+		inviteQuota:          envInt("INVITE_QUOTA", 5),
+		inviteQuotaRed:       envInt("INVITE_QUOTA_RED", 25),
+		pow: &proofOfWork{
+			key:                auth.DeriveKey(secret, "pow"),
+			baseDifficulty:     envInt("POW_DIFFICULTY", 0),
+			maxDifficulty:      envInt("POW_MAX_DIFFICULTY", 24),
+			signupsPerStep:     envInt("POW_SIGNUPS_PER_HOUR", 20),
+			loginAfterFailures: envInt("POW_LOGIN_AFTER_FAILURES", 0),
+		},
+		chirpEditWindow:    envDuration("CHIRP_EDIT_WINDOW", 15*time.Minute),
+		chirpEditWindowRed: envDuration("CHIRP_EDIT_WINDOW_RED", time.Hour),
+	}
+	if apiCfg.pow.baseDifficulty > apiCfg.pow.maxDifficulty {
+		log.Fatalf("POW_DIFFICULTY (%d) can't be more than POW_MAX_DIFFICULTY (%d)", apiCfg.pow.baseDifficulty, apiCfg.pow.maxDifficulty)
+	}
+	apiCfg.revocations = auth.NewRevocationCache(envDuration("REVOCATION_CACHE_TTL", 30*time.Second), apiCfg.loadRevocations)
+	keys.SetRevocations(apiCfg.revocations)
 
 	mux := http.NewServeMux()
 	fileServer := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
 
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(fileServer))
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerCount)
+	mux.HandleFunc("GET /admin/metrics", apiCfg.requireRole(roleAdmin, apiCfg.handlerCount))
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.handlerSearchChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirpByID)
-
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerChirpRevisions)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.handlerHashtagChirps)
+	mux.HandleFunc("GET /api/trending", apiCfg.handlerTrending)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+	mux.HandleFunc("GET /api/challenge", apiCfg.handlerChallenge)
+
+	mux.HandleFunc("POST /admin/reset", apiCfg.requireRole(roleAdmin, apiCfg.handlerReset))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", apiCfg.requireRole(roleAdmin, apiCfg.handlerSetUserRole))
+	mux.HandleFunc("GET /admin/audit", apiCfg.requireRole(roleAdmin, apiCfg.handlerAdminAudit))
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerSendChirp)
 	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.handlerLoginMFA)
+	mux.HandleFunc("GET /api/login/oidc", apiCfg.handlerOIDCLogin)
+	mux.HandleFunc("GET /api/login/oidc/callback", apiCfg.handlerOIDCCallback)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
+	mux.HandleFunc("POST /api/users/verify", apiCfg.handlerVerifyEmail)
+	mux.HandleFunc("POST /api/users/verify/resend", apiCfg.handlerResendVerification)
+	mux.HandleFunc("POST /api/mfa/totp", apiCfg.handlerTOTPEnroll)
+	mux.HandleFunc("POST /api/mfa/totp/confirm", apiCfg.handlerTOTPConfirm)
+	mux.HandleFunc("POST /api/mfa/totp/disable", apiCfg.handlerTOTPDisable)
+	mux.HandleFunc("POST /api/password-reset", apiCfg.handlerPasswordReset)
+	mux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlerPasswordResetConfirm)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUserUpgraded)
 
+	mux.HandleFunc("GET /api/sessions", apiCfg.handlerListSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.handlerRevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.handlerRevokeAllSessions)
+	mux.HandleFunc("GET /api/tokens", apiCfg.handlerListTokens)
+	mux.HandleFunc("POST /api/tokens", apiCfg.handlerCreateToken)
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.handlerRevokeToken)
+	mux.HandleFunc("GET /api/invites", apiCfg.handlerListInvites)
+	mux.HandleFunc("POST /api/invites", apiCfg.handlerCreateInvite)
+	mux.HandleFunc("DELETE /api/invites/{inviteID}", apiCfg.handlerRevokeInvite)
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.handlerListOAuthClients)
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.handlerCreateOAuthClient)
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.handlerDeleteOAuthClient)
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.handlerAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.handlerAuthorizeConsent)
+	mux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
+
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUsers)
+	mux.HandleFunc("PUT /api/users/handle", apiCfg.handlerSetHandle)
+	mux.HandleFunc("DELETE /api/users", apiCfg.handlerDeleteUser)
+	mux.HandleFunc("GET /api/me/audit", apiCfg.handlerMyAudit)
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerListNotifications)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerReadNotifications)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerEditChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDelete)
 
+	go apiCfg.runMaintenance()
+
 	server := http.Server{
 		Handler: mux,
 		Addr:    ":8080",
@@ -111,6 +319,32 @@ func main() {
 
 }
 
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be a number: %s", name, err)
+	}
+	return n
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration: %s", name, err)
+	}
+	return d
+}
+
 func handlerReadiness(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
@@ -119,7 +353,7 @@ func handlerReadiness(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-func (cfg *apiConfig) handlerCount(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handlerCount(w http.ResponseWriter, r *http.Request, _ principal) {
 
 	currentCount := cfg.fileServerHits.Load()
 
@@ -132,7 +366,13 @@ func (cfg *apiConfig) handlerCount(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	respondWithJSON(w, http.StatusOK, cfg.keys.JWKS())
+}
+
+func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request, p principal) {
 
 	if cfg.platform != "dev" {
 		respondWithError(w, 403, "Forbidden", nil)
@@ -145,6 +385,8 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg.audit(r, auditAdminReset, p.UserID, uuid.Nil, nil)
+
 	w.WriteHeader(http.StatusOK)
 
 }
@@ -160,8 +402,11 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 
 	type user struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password     string `json:"password"`
+		Email        string `json:"email"`
+		InviteCode   string `json:"invite_code"`
+		PowChallenge string `json:"pow_challenge"`
+		PowSolution  string `json:"pow_solution"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -172,34 +417,73 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if cfg.registrationMode == registrationClosed {
+		respondWithError(w, http.StatusForbidden, errRegistrationClosed.Error(), nil)
+		return
+	}
+
+	if cfg.pow.enabled() && !cfg.checkProofOfWork(w, r, powPurposeSignup, params.PowChallenge, params.PowSolution) {
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(w, params.Password) {
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to hash password", err)
+		return
+	}
 
 	dbUserParams := database.CreateUserParams{
 		HashedPassword: hashedPassword,
 		Email:          params.Email,
 	}
 
-	dbUser, err := cfg.db.CreateUser(r.Context(), dbUserParams)
+	dbUser, err := cfg.registerUser(r.Context(), dbUserParams, params.InviteCode)
+	if errors.Is(err, errRegistrationClosed) || errors.Is(err, errInviteRequired) || errors.Is(err, errInvalidInvite) {
+		respondWithError(w, http.StatusForbidden, err.Error(), nil)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create user", err)
 		return
 	}
 
-	userResp := User{
-		ID:        dbUser.ID,
-		CreatedAt: dbUser.CreatedAt,
-		UpdatedAt: dbUser.UpdatedAt,
-		Email:     dbUser.Email,
+	err = cfg.sendEmailVerification(r.Context(), dbUser)
+	if err != nil {
+		log.Printf("failed to send verification email to user %s: %s", dbUser.ID, err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, databaseUserToApi(dbUser))
+}
+
+// checkPasswordPolicy writes a 400 listing every failed rule when password
+// isn't acceptable and reports whether the caller may continue.
+func (cfg *apiConfig) checkPasswordPolicy(w http.ResponseWriter, password string) bool {
+	violations := cfg.passwordPolicy.Check(password)
+	if len(violations) == 0 {
+		return true
 	}
 
-	respondWithJSON(w, http.StatusCreated, userResp)
+	respondWithJSON(w, http.StatusBadRequest, struct {
+		Error      string                   `json:"error"`
+		Violations []auth.PasswordViolation `json:"violations"`
+	}{
+		Error:      "password does not meet requirements",
+		Violations: violations,
+	})
+	return false
 }
 
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 
 	type user struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password     string `json:"password"`
+		Email        string `json:"email"`
+		PowChallenge string `json:"pow_challenge"`
+		PowSolution  string `json:"pow_solution"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -211,64 +495,159 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	emailKey := emailThrottleKey(params.Email)
+	ipKey := ipThrottleKey(cfg.clientIP(r))
+
+	// a blocked caller is turned away before it has to solve a challenge, the
+	// attempt itself is reserved once it has
+	wait, err := cfg.loginRetryAfter(r.Context(), emailKey, ipKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
+	needsWork, err := cfg.loginNeedsProofOfWork(r.Context(), emailKey, ipKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	if needsWork && !cfg.checkProofOfWork(w, r, powPurposeLogin, params.PowChallenge, params.PowSolution) {
+		return
+	}
+
+	wait, err = cfg.reserveLoginAttempt(r.Context(), emailKey, ipKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
 	dbUser, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
+		cfg.audit(r, auditLoginFailed, uuid.Nil, uuid.Nil, map[string]interface{}{
+			"email": params.Email,
+		})
 		respondWithError(w, 401, "incorrect email or password", err)
 		return
 	}
 
-	err = auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
+	needsRehash, err := auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
 	if err != nil {
+		cfg.audit(r, auditLoginFailed, uuid.Nil, dbUser.ID, map[string]interface{}{
+			"email": params.Email,
+		})
 		respondWithError(w, 401, "incorrect email or password", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(dbUser.ID, cfg.secret, 1*time.Hour)
+	cfg.releaseLoginAttempt(r.Context(), emailKey, ipKey)
+
+	if needsRehash {
+		cfg.rehashPassword(r.Context(), dbUser.ID, params.Password)
+	}
+
+	cfg.loginUser(w, r, dbUser)
+}
+
+// loginUser is called once a user has proved who they are, either with their
+// password or through single sign-on. Users with two-factor authentication
+// still have to enter a code before they get tokens.
+func (cfg *apiConfig) loginUser(w http.ResponseWriter, r *http.Request, dbUser database.User) {
+	if dbUser.TotpEnabledAt.Valid {
+		mfaToken, err := cfg.keys.MakeMFAToken(dbUser.ID, mfaTokenTTL)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to make token", err)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
+
+	cfg.completeLogin(w, r, dbUser)
+}
+
+// rehashPassword replaces a hash made with an old algorithm or old parameters.
+// Failing to do so doesn't stop the login, it will be tried again next time.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashedPassword, err := auth.HashPassword(password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to make token", err)
+		log.Printf("failed to rehash password for %s: %s", userID, err)
 		return
 	}
 
-	refreshToken, _ := auth.MakeRefreshToken()
+	err = cfg.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		log.Printf("failed to save rehashed password for %s: %s", userID, err)
+	}
+}
+
+// completeLogin issues a fresh access and refresh token pair once a user has
+// passed every authentication step.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, dbUser database.User) {
+	cfg.clearLoginFailures(r.Context(), emailThrottleKey(dbUser.Email))
 
-	userResp := User{
-		ID:           dbUser.ID,
-		CreatedAt:    dbUser.CreatedAt,
-		UpdatedAt:    dbUser.UpdatedAt,
-		Email:        dbUser.Email,
-		Token:        accessToken,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  dbUser.IsChirpyRed,
+	if dbUser.DeletionRequestedAt.Valid {
+		err := cfg.cancelUserDeletion(r.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to cancel account deletion", err)
+			return
+		}
 	}
 
-	refreshResp := database.RefreshToken{
-		Token:     refreshToken,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID:    dbUser.ID,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour),
-		RevokedAt: sql.NullTime{Time: time.Time{}, Valid: false},
+	grant := refreshGrant{
+		userID:   dbUser.ID,
+		familyID: uuid.New(),
 	}
 
-	refreshParams := database.InsertRefreshTokenParams{
-		Token:     refreshResp.Token,
-		CreatedAt: refreshResp.CreatedAt,
-		UpdatedAt: refreshResp.UpdatedAt,
-		UserID:    refreshResp.UserID,
-		ExpiresAt: refreshResp.ExpiresAt,
-		RevokedAt: refreshResp.RevokedAt,
+	accessToken, accessTokenID, err := cfg.makeAccessToken(grant)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to make token", err)
+		return
 	}
 
-	err = cfg.db.InsertRefreshToken(r.Context(), refreshParams)
+	refreshToken, _, err := cfg.issueRefreshToken(r, grant, accessTokenID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "failed to insert refresh_token", err)
 		return
 	}
 
+	cfg.audit(r, auditLogin, dbUser.ID, dbUser.ID, map[string]interface{}{
+		"session_id": grant.familyID,
+	})
+
+	if cfg.wantsCookieSession(r) {
+		cfg.setSessionCookies(w, accessToken, refreshToken, grant.familyID)
+		respondWithJSON(w, http.StatusOK, databaseUserToApi(dbUser))
+		return
+	}
+
+	userResp := databaseUserToApi(dbUser)
+	userResp.Token = accessToken
+	userResp.RefreshToken = refreshToken
+
 	respondWithJSON(w, http.StatusOK, userResp)
 }
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
+	if cfg.usesCookieSession(r) {
+		cfg.refreshCookieSession(w, r)
+		return
+	}
+
 	authHeader := r.Header.Get("Authorization")
 
 	if authHeader == "" {
@@ -285,72 +664,252 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	refreshToken := strings.TrimPrefix(authHeader, prefix)
 	refreshToken = strings.TrimSpace(refreshToken)
 
-	dbToken, err := cfg.db.GetRefreshToken(r.Context(), refreshToken)
-	if err != nil {
+	dbToken, err := cfg.getActiveRefreshToken(r.Context(), refreshToken)
+	if errors.Is(err, errInvalidRefreshToken) {
 		respondWithError(w, 401, "invalid or expired token", err)
 		return
 	}
-
-	if dbToken.ExpiresAt.Before(time.Now()) {
-		respondWithError(w, 401, "refresh token expired", nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
 		return
 	}
 
-	if dbToken.RevokedAt.Valid {
-		respondWithError(w, 401, "refresh token revoked", nil)
+	// tokens handed to OAuth clients can only be refreshed through /oauth/token
+	if dbToken.ClientID.Valid {
+		respondWithError(w, 401, "invalid or expired token", nil)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(dbToken.UserID, cfg.secret, 1*time.Hour)
+	accessToken, newRefreshToken, err := cfg.rotateRefreshToken(r, dbToken)
+	if errors.Is(err, errInvalidRefreshToken) {
+		respondWithError(w, 401, "invalid or expired token", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to create token", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to rotate refresh token", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"token": accessToken})
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"token":         accessToken,
+		"refresh_token": newRefreshToken,
+	})
+}
+
+// refreshGrant is what a refresh token family was issued for. Families
+// belonging to an OAuth client are limited to the scopes the user consented to.
+type refreshGrant struct {
+	userID   uuid.UUID
+	familyID uuid.UUID
+	clientID uuid.NullUUID
+	scopes   []string
+}
+
+func grantFromRefreshToken(dbToken database.RefreshToken) refreshGrant {
+	return refreshGrant{
+		userID:   dbToken.UserID,
+		familyID: dbToken.FamilyID,
+		clientID: dbToken.ClientID,
+		scopes:   dbToken.Scopes,
+	}
+}
+
+// makeAccessToken returns an access token for grant along with its jti, which
+// is recorded on the refresh token issued with it so the two can be revoked
+// together.
+func (cfg *apiConfig) makeAccessToken(grant refreshGrant) (string, uuid.UUID, error) {
+	tokenID := uuid.New()
+	opts := []auth.TokenOption{auth.WithSessionID(grant.familyID), auth.WithTokenID(tokenID)}
+	if grant.clientID.Valid {
+		opts = append(opts, auth.WithClientID(grant.clientID.UUID), auth.WithScopes(grant.scopes))
+	}
+
+	accessToken, err := cfg.keys.MakeJWT(grant.userID, accessTokenTTL, opts...)
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+
+	return accessToken, tokenID, nil
+}
+
+func (cfg *apiConfig) issueRefreshToken(r *http.Request, grant refreshGrant, accessTokenID uuid.UUID) (string, database.RefreshToken, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", database.RefreshToken{}, err
+	}
+
+	dbToken, err := cfg.db.InsertRefreshToken(r.Context(), database.InsertRefreshTokenParams{
+		TokenHash:     auth.HashToken(refreshToken, cfg.secret),
+		TokenPrefix:   auth.TokenPrefix(refreshToken),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		UserID:        grant.userID,
+		ExpiresAt:     time.Now().Add(refreshTokenTTL),
+		RevokedAt:     sql.NullTime{Time: time.Time{}, Valid: false},
+		FamilyID:      grant.familyID,
+		LastUsedAt:    time.Now(),
+		UserAgent:     truncate(r.UserAgent(), maxUserAgentLength),
+		IpAddress:     cfg.clientIP(r),
+		ClientID:      grant.clientID,
+		Scopes:        grant.scopes,
+		AccessTokenID: uuid.NullUUID{UUID: accessTokenID, Valid: true},
+	})
+	if err != nil {
+		return "", database.RefreshToken{}, err
+	}
+	cfg.revocations.Exempt(accessTokenID.String())
+
+	return refreshToken, dbToken, nil
+}
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// getActiveRefreshToken looks up a refresh token that may still be used.
+// Presenting a revoked token revokes its whole family.
+func (cfg *apiConfig) getActiveRefreshToken(ctx context.Context, refreshToken string) (database.RefreshToken, error) {
+	dbToken, err := cfg.db.GetRefreshToken(ctx, auth.HashToken(refreshToken, cfg.secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.RefreshToken{}, errInvalidRefreshToken
+	}
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	if dbToken.RevokedAt.Valid {
+		cfg.revokeRefreshTokenFamily(ctx, dbToken)
+		return database.RefreshToken{}, fmt.Errorf("%w: revoked", errInvalidRefreshToken)
+	}
+
+	if dbToken.ExpiresAt.Before(time.Now()) {
+		return database.RefreshToken{}, fmt.Errorf("%w: expired", errInvalidRefreshToken)
+	}
+
+	return dbToken, nil
+}
+
+// rotateRefreshToken replaces dbToken with a new token in the same family and
+// makes an access token to go with it.
+func (cfg *apiConfig) rotateRefreshToken(r *http.Request, dbToken database.RefreshToken) (string, string, error) {
+	grant := grantFromRefreshToken(dbToken)
+
+	accessToken, accessTokenID, err := cfg.makeAccessToken(grant)
+	if err != nil {
+		return "", "", err
+	}
+
+	newRefreshToken, newDBToken, err := cfg.issueRefreshToken(r, grant, accessTokenID)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = cfg.db.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+		ID:         dbToken.ID,
+		ReplacedBy: uuid.NullUUID{UUID: newDBToken.ID, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// another request rotated this token first, so it is being replayed
+		cfg.revokeRefreshTokenFamily(r.Context(), dbToken)
+		return "", "", fmt.Errorf("%w: revoked", errInvalidRefreshToken)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, newRefreshToken, nil
+}
+
+// revokeRefreshTokenFamily is called when an already revoked refresh token is
+// presented again. Every token descended from the same login is revoked.
+func (cfg *apiConfig) revokeRefreshTokenFamily(ctx context.Context, dbToken database.RefreshToken) {
+	log.Printf("refresh token %s... reused for user %s, revoking token family %s", dbToken.TokenPrefix, dbToken.UserID, dbToken.FamilyID)
+
+	err := cfg.db.RevokeRefreshTokenFamily(ctx, dbToken.FamilyID)
+	if err != nil {
+		log.Printf("failed to revoke token family %s: %s", dbToken.FamilyID, err)
+	}
+
+	err = cfg.revokeSessionAccessTokens(ctx, dbToken.FamilyID)
+	if err != nil {
+		log.Printf("failed to revoke access tokens for family %s: %s", dbToken.FamilyID, err)
+	}
 }
 
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
+	if cfg.usesCookieSession(r) {
+		cfg.revokeCookieSession(w, r)
+		return
+	}
+
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Couldn't find token", err)
 		return
 	}
 
-	_, err = cfg.db.RevokeRefreshToken(r.Context(), refreshToken)
+	dbToken, err := cfg.db.RevokeRefreshToken(r.Context(), auth.HashToken(refreshToken, cfg.secret))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
 		return
 	}
 
+	err = cfg.revokeAccessTokenFor(r.Context(), dbToken)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke access token", err)
+		return
+	}
+
+	cfg.audit(r, auditSessionRevoked, dbToken.UserID, dbToken.UserID, map[string]interface{}{
+		"session_id": dbToken.FamilyID,
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 
 	type user struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password        string `json:"password"`
+		Email           string `json:"email"`
+		CurrentPassword string `json:"current_password"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	p, ok := cfg.authorize(w, r, auth.ScopeUsersWrite)
+	if !ok {
+		return
+	}
+	userID := p.UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := user{}
+	err := decoder.Decode(&params)
+
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find token", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to decode Json", err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	oldUser, err := cfg.db.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invaild token", err)
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := user{}
-	err = decoder.Decode(&params)
+	// a leaked personal access token or a client the user approved shouldn't
+	// be enough to take the account over, so they have to know the password
+	if p.delegated() {
+		_, err = auth.CheckPasswordHash(params.CurrentPassword, oldUser.HashedPassword)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "current password is incorrect", err)
+			return
+		}
+	}
 
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to decode Json", err)
+	_, err = auth.CheckPasswordHash(params.Password, oldUser.HashedPassword)
+	passwordChanged := err != nil
+
+	// only new passwords are held to the policy, so existing users can still
+	// update their email before they pick a better one
+	if passwordChanged && !cfg.checkPasswordPolicy(w, params.Password) {
 		return
 	}
 
@@ -372,14 +931,40 @@ func (cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userResp := User{
-		ID:        dbUser.ID,
-		CreatedAt: dbUser.CreatedAt,
-		UpdatedAt: dbUser.UpdatedAt,
-		Email:     dbUser.Email,
+	if passwordChanged {
+		// the password changed, so every other login has to authenticate again
+		sessionID, _ := uuid.Parse(p.SessionID)
+		err = cfg.db.RevokeOtherRefreshTokens(r.Context(), database.RevokeOtherRefreshTokensParams{
+			UserID:   userID,
+			FamilyID: sessionID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to revoke other sessions", err)
+			return
+		}
+
+		err = cfg.revokeOtherSessionAccessTokens(r.Context(), userID, sessionID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to revoke other sessions", err)
+			return
+		}
+
+		cfg.audit(r, auditPasswordChanged, userID, userID, nil)
+	}
+
+	if dbUser.Email != oldUser.Email {
+		cfg.audit(r, auditEmailChanged, userID, userID, map[string]interface{}{
+			"old_email": oldUser.Email,
+			"new_email": dbUser.Email,
+		})
+
+		err = cfg.sendEmailVerification(r.Context(), dbUser)
+		if err != nil {
+			log.Printf("failed to send verification email to user %s: %s", dbUser.ID, err)
+		}
 	}
 
-	respondWithJSON(w, http.StatusOK, userResp)
+	respondWithJSON(w, http.StatusOK, databaseUserToApi(dbUser))
 }
 
 func (cfg *apiConfig) handlerSendChirp(w http.ResponseWriter, r *http.Request) {
@@ -397,96 +982,153 @@ func (cfg *apiConfig) handlerSendChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, 401, "Unable to locate token", err)
+	p, ok := cfg.authorize(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
 	}
 
-	claims, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		respondWithError(w, 401, "invalid token", err)
+	if cfg.requireVerifiedEmail {
+		dbUser, err := cfg.db.GetUserByID(r.Context(), p.UserID)
+		if err != nil {
+			respondWithError(w, 401, "user not found", err)
+			return
+		}
+		if !dbUser.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusForbidden, "email address must be verified", nil)
+			return
+		}
 	}
 
 	dbChirpParams := database.AddChirpParams{
 		Body:   params.Body,
-		UserID: claims,
+		UserID: p.UserID,
 	}
-	const maxLength = 140
 
-	if len(params.Body) > maxLength {
+	if len(params.Body) > maxChirpLength {
 		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
 		return
 	}
 
-	dbChirp, err := cfg.db.AddChirp(r.Context(), dbChirpParams)
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	dbChirp, err := q.AddChirp(r.Context(), dbChirpParams)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to add chirp", err)
 		return
 	}
 
-	chirpResp := Chirps{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		User_ID:   dbChirp.UserID,
+	err = tagChirp(r.Context(), q, dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to add chirp", err)
+		return
+	}
+
+	err = mentionChirp(r.Context(), q, dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to add chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to add chirp", err)
+		return
+	}
+
+	chirpResp, err := cfg.chirpToApi(r.Context(), dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusCreated, chirpResp)
 }
 
+// handlerGetChirps lists chirps a page at a time, oldest first unless
+// sort=desc. The Link header points at the next page when there is one.
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 
 	s := r.URL.Query().Get("author_id")
 	query := r.URL.Query().Get("sort")
-	var apiChirps []Chirps
 
+	authorID := uuid.NullUUID{}
 	if s != "" {
-		authorID, err := uuid.Parse(s)
+		id, err := uuid.Parse(s)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "no id found", err)
 			return
 		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
 
-		authorChirps, err := cfg.db.GetChirpsByID(r.Context(), authorID)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
-			return
-		}
-
-		apiChirps = make([]Chirps, len(authorChirps))
-		for i, authorChirp := range authorChirps {
-			apiChirps[i] = databaseChirpToApi(authorChirp)
-		}
-
-	} else {
-		dbChirps, err := cfg.db.GetChirps(r.Context())
+	limit, ok := pageSize(r, defaultChirpPageSize, maxChirpPageSize)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+		return
+	}
 
+	params := database.ListChirpsParams{
+		AuthorID: authorID,
+		// one extra row tells us whether there is another page
+		MaxResults: limit + 1,
+	}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := pagination.Decode(c)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
 			return
 		}
-
-		apiChirps = make([]Chirps, len(dbChirps))
-		for i, dbChirp := range dbChirps {
-			apiChirps[i] = databaseChirpToApi(dbChirp)
-		}
-
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
 	}
 
+	var dbChirps []database.Chirp
+	var err error
 	if query == "desc" {
-		sort.Slice(apiChirps, func(i, j int) bool {
-			return apiChirps[i].CreatedAt.After(apiChirps[j].CreatedAt)
-		})
+		dbChirps, err = cfg.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams(params))
 	} else {
-		sort.Slice(apiChirps, func(i, j int) bool {
-			return apiChirps[i].CreatedAt.Before(apiChirps[j].CreatedAt)
-		})
+		dbChirps, err = cfg.db.ListChirps(r.Context(), params)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	if len(dbChirps) > int(limit) {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		setNextLink(w, r, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	apiChirps, err := cfg.chirpsToApi(r.Context(), dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, apiChirps)
 }
 
+func databaseUserToApi(dbUser database.User) User {
+	return User{
+		ID:            dbUser.ID,
+		CreatedAt:     dbUser.CreatedAt,
+		UpdatedAt:     dbUser.UpdatedAt,
+		Email:         dbUser.Email,
+		IsChirpyRed:   dbUser.IsChirpyRed,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+		TOTPEnabled:   dbUser.TotpEnabledAt.Valid,
+		Role:          dbUser.Role,
+		Handle:        dbUser.Handle.String,
+	}
+}
+
 func databaseChirpToApi(dbChirp database.Chirp) Chirps {
 	return Chirps{
 		ID:        dbChirp.ID,
@@ -494,6 +1136,8 @@ func databaseChirpToApi(dbChirp database.Chirp) Chirps {
 		UpdatedAt: dbChirp.UpdatedAt,
 		Body:      dbChirp.Body,
 		User_ID:   dbChirp.UserID,
+		Edited:    dbChirp.UpdatedAt.After(dbChirp.CreatedAt),
+		Mentions:  []ChirpMention{},
 	}
 }
 
@@ -510,29 +1154,21 @@ func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	chirpResp := Chirps{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		User_ID:   dbChirp.UserID,
+	chirpResp, err := cfg.chirpToApi(r.Context(), dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, chirpResp)
 }
 
 func (cfg *apiConfig) handlerDelete(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, 401, "couldn't find token", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		respondWithError(w, 401, "invalid token", err)
+	p, ok := cfg.authorize(w, r, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
+	userID := p.UserID
 
 	id := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(id)
@@ -549,13 +1185,28 @@ func (cfg *apiConfig) handlerDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if dbChirp.UserID != userID {
-		respondWithError(w, 403, "not authorized", nil)
-		return
+		// moderators can take down anyone's chirps, but only themselves, not
+		// through a token they gave to a script or an app
+		if p.delegated() {
+			respondWithError(w, 403, "not authorized", nil)
+			return
+		}
+		dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, 401, "user not found", err)
+			return
+		}
+		if !hasRole(dbUser, roleModerator) {
+			respondWithError(w, 403, "not authorized", nil)
+			return
+		}
 	}
 
+	// its chirp_hashtags rows are deleted with it, so the chirp stops counting
+	// towards its tags straight away
 	err = cfg.db.DeleteChirpByID(r.Context(), database.DeleteChirpByIDParams{
 		ID:     chirpID,
-		UserID: userID,
+		UserID: dbChirp.UserID,
 	})
 
 	if err != nil {
@@ -608,6 +1259,10 @@ func (cfg *apiConfig) handlerUserUpgraded(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	cfg.audit(r, auditUserUpgraded, uuid.Nil, params.Data.UserID, map[string]interface{}{
+		"source": "polka",
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const maintenanceInterval = time.Hour
+
+// runMaintenance periodically clears out rows that are no longer needed.
+func (cfg *apiConfig) runMaintenance() {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cfg.maintain(context.Background())
+	}
+}
+
+func (cfg *apiConfig) maintain(ctx context.Context) {
+	err := cfg.db.DeleteStaleLoginThrottles(ctx, time.Now().Add(-cfg.loginThrottle.lockout))
+	if err != nil {
+		log.Printf("failed to delete stale login throttles: %s", err)
+	}
+
+	err = cfg.db.DeleteExpiredAuthorizationCodes(ctx, time.Now())
+	if err != nil {
+		log.Printf("failed to delete expired authorization codes: %s", err)
+	}
+
+	err = cfg.db.DeleteExpiredRevokedAccessTokens(ctx, time.Now())
+	if err != nil {
+		log.Printf("failed to delete expired revoked access tokens: %s", err)
+	}
+
+	err = cfg.db.DeleteExpiredPowRedemptions(ctx, time.Now())
+	if err != nil {
+		log.Printf("failed to delete expired proof of work redemptions: %s", err)
+	}
+
+	cfg.deleteExpiredAccounts(ctx)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/entities"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ChirpMention is an @handle in a chirp that belongs to a user. Start and End
+// are offsets into the body in Unicode code points, End exclusive.
+type ChirpMention struct {
+	UserID uuid.UUID `json:"user_id"`
+	Handle string    `json:"handle"`
+	Start  int32     `json:"start"`
+	End    int32     `json:"end"`
+}
+
+// mentionChirp records the users a chirp mentions and lets them know. It is
+// run in the same transaction that saves the chirp. Handles nobody has are
+// left as plain text.
+func mentionChirp(ctx context.Context, q *database.Queries, dbChirp database.Chirp) error {
+	mentions := entities.Mentions(dbChirp.Body)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	handles := make([]string, len(mentions))
+	for i, mention := range mentions {
+		handles[i] = mention.Handle
+	}
+
+	dbUsers, err := q.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return err
+	}
+
+	users := map[string]uuid.UUID{}
+	for _, dbUser := range dbUsers {
+		users[dbUser.Handle.String] = dbUser.ID
+	}
+
+	for _, mention := range mentions {
+		userID, ok := users[mention.Handle]
+		if !ok {
+			continue
+		}
+
+		err = q.InsertChirpMention(ctx, database.InsertChirpMentionParams{
+			ChirpID:     dbChirp.ID,
+			UserID:      userID,
+			StartOffset: int32(mention.Start),
+			EndOffset:   int32(mention.End),
+		})
+		if err != nil {
+			return err
+		}
+
+		if userID == dbChirp.UserID {
+			continue
+		}
+
+		// a user mentioned twice, or again after an edit, is only told once
+		err = q.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  userID,
+			Type:    notificationMention,
+			ActorID: dbChirp.UserID,
+			ChirpID: dbChirp.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chirpsToApi is databaseChirpToApi for a list of chirps, with their mentions
+// looked up in one query.
+func (cfg *apiConfig) chirpsToApi(ctx context.Context, dbChirps []database.Chirp) ([]Chirps, error) {
+	apiChirps := make([]Chirps, len(dbChirps))
+	if len(dbChirps) == 0 {
+		return apiChirps, nil
+	}
+
+	chirpIDs := make([]uuid.UUID, len(dbChirps))
+	for i, dbChirp := range dbChirps {
+		chirpIDs[i] = dbChirp.ID
+	}
+
+	dbMentions, err := cfg.db.ListChirpMentions(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mentions := map[uuid.UUID][]ChirpMention{}
+	for _, dbMention := range dbMentions {
+		mentions[dbMention.ChirpID] = append(mentions[dbMention.ChirpID], ChirpMention{
+			UserID: dbMention.UserID,
+			Handle: dbMention.Handle.String,
+			Start:  dbMention.StartOffset,
+			End:    dbMention.EndOffset,
+		})
+	}
+
+	for i, dbChirp := range dbChirps {
+		apiChirps[i] = databaseChirpToApi(dbChirp)
+		if found, ok := mentions[dbChirp.ID]; ok {
+			apiChirps[i].Mentions = found
+		}
+	}
+
+	return apiChirps, nil
+}
+
+func (cfg *apiConfig) chirpToApi(ctx context.Context, dbChirp database.Chirp) (Chirps, error) {
+	apiChirps, err := cfg.chirpsToApi(ctx, []database.Chirp{dbChirp})
+	if err != nil {
+		return Chirps{}, err
+	}
+	return apiChirps[0], nil
+}
+
+// handlerSetHandle claims the @handle other users can mention you by.
+func (cfg *apiConfig) handlerSetHandle(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		Handle string `json:"handle"`
+	}
+
+	p, ok := cfg.authorize(w, r, auth.ScopeUsersWrite)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	handle, ok := entities.NormalizeHandle(params.Handle)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "handles are up to 30 letters, digits and underscores, with at least one letter", nil)
+		return
+	}
+
+	dbUser, err := cfg.db.SetUserHandle(r.Context(), database.SetUserHandleParams{
+		ID:     p.UserID,
+		Handle: sql.NullString{String: handle, Valid: true},
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondWithError(w, http.StatusConflict, "handle is already taken", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update handle", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseUserToApi(dbUser))
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+)
+
+const (
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "Chirpy"
+)
+
+func (cfg *apiConfig) handlerTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+	userID := p.UserID
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+
+	if dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled", nil)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to generate secret", err)
+		return
+	}
+
+	encrypted, err := auth.Encrypt([]byte(secret), cfg.totpKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to encrypt secret", err)
+		return
+	}
+
+	err = cfg.db.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		ID:         dbUser.ID,
+		TotpSecret: sql.NullString{String: encrypted, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to save secret", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(totpIssuer, dbUser.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) handlerTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		Code string `json:"code"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+	userID := p.UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+
+	if dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled", nil)
+		return
+	}
+	if !dbUser.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "two-factor enrollment has not been started", nil)
+		return
+	}
+
+	ok, err = cfg.checkTOTP(r.Context(), dbUser, params.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to check code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "invalid code", nil)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to generate recovery codes", err)
+		return
+	}
+
+	err = cfg.db.DeleteRecoveryCodes(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to save recovery codes", err)
+		return
+	}
+
+	for _, code := range codes {
+		err = cfg.db.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   dbUser.ID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code), cfg.secret),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to save recovery codes", err)
+			return
+		}
+	}
+
+	err = cfg.db.EnableTOTP(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to enable two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+func (cfg *apiConfig) handlerTOTPDisable(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+	userID := p.UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+
+	if !dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusBadRequest, "two-factor authentication is not enabled", nil)
+		return
+	}
+
+	// an access token alone isn't enough, the user has to prove both factors again
+	_, err = auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "incorrect password or code", err)
+		return
+	}
+
+	ok, err = cfg.checkMFACode(r.Context(), dbUser, params.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to check code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "incorrect password or code", nil)
+		return
+	}
+
+	err = cfg.db.DisableTOTP(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to disable two-factor authentication", err)
+		return
+	}
+
+	err = cfg.db.DeleteRecoveryCodes(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete recovery codes", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	userID, err := cfg.keys.ValidateMFAToken(params.MFAToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired mfa token", err)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "user not found", err)
+		return
+	}
+
+	if !dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "two-factor authentication is not enabled", nil)
+		return
+	}
+
+	// codes are only six digits, so guesses are throttled like passwords
+	emailKey := emailThrottleKey(dbUser.Email)
+	ipKey := ipThrottleKey(cfg.clientIP(r))
+
+	wait, err := cfg.reserveLoginAttempt(r.Context(), emailKey, ipKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
+	ok, err := cfg.checkMFACode(r.Context(), dbUser, params.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to check code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "invalid code", nil)
+		return
+	}
+	cfg.releaseLoginAttempt(r.Context(), emailKey, ipKey)
+
+	cfg.completeLogin(w, r, dbUser)
+}
+
+// checkMFACode accepts either a current TOTP code or one of the user's unused
+// recovery codes.
+func (cfg *apiConfig) checkMFACode(ctx context.Context, dbUser database.User, code string) (bool, error) {
+	ok, err := cfg.checkTOTP(ctx, dbUser, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	rows, err := cfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   dbUser.ID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code), cfg.secret),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+func (cfg *apiConfig) checkTOTP(ctx context.Context, dbUser database.User, code string) (bool, error) {
+	if !dbUser.TotpSecret.Valid {
+		return false, nil
+	}
+
+	secret, err := auth.Decrypt(dbUser.TotpSecret.String, cfg.totpKey)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := auth.ValidateTOTP(string(secret), code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	// each code is only good once, even inside its time window
+	rows, err := cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		ID:           dbUser.ID,
+		TotpLastStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationMention = "mention"
+
+	defaultNotificationPageSize = 50
+	maxNotificationPageSize     = 100
+)
+
+type Notification struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Type      string    `json:"type"`
+	ActorID   uuid.UUID `json:"actor_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Read      bool      `json:"read"`
+}
+
+// handlerListNotifications lists a user's notifications, newest first, a
+// page at a time like handlerGetChirps. unread=true leaves out the ones
+// already read.
+func (cfg *apiConfig) handlerListNotifications(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageSize(r, defaultNotificationPageSize, maxNotificationPageSize)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+		return
+	}
+
+	params := database.ListNotificationsParams{
+		UserID:     p.UserID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		// one extra row tells us whether there is another page
+		MaxResults: limit + 1,
+	}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := pagination.Decode(c)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	dbNotifications, err := cfg.db.ListNotifications(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	if len(dbNotifications) > int(limit) {
+		dbNotifications = dbNotifications[:limit]
+		last := dbNotifications[len(dbNotifications)-1]
+		setNextLink(w, r, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	notifications := make([]Notification, len(dbNotifications))
+	for i, dbNotification := range dbNotifications {
+		notifications[i] = Notification{
+			ID:        dbNotification.ID,
+			CreatedAt: dbNotification.CreatedAt,
+			Type:      dbNotification.Type,
+			ActorID:   dbNotification.ActorID,
+			ChirpID:   dbNotification.ChirpID,
+			Read:      dbNotification.ReadAt.Valid,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, notifications)
+}
+
+// handlerReadNotifications marks the notifications in ids as read, or all of
+// them if there are no ids.
+func (cfg *apiConfig) handlerReadNotifications(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	if len(params.IDs) == 0 {
+		err = cfg.db.MarkAllNotificationsRead(r.Context(), p.UserID)
+	} else {
+		err = cfg.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			UserID: p.UserID,
+			Ids:    params.IDs,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to mark notifications read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,568 @@
+package main
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const authorizationCodeTTL = 10 * time.Minute
+
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:  "Read chirps",
+	auth.ScopeChirpsWrite: "Post and delete chirps as you",
+	auth.ScopeUsersWrite:  "Change your email address and password",
+}
+
+// oauthError is an error response from RFC 6749, sent either as JSON from the
+// token endpoint or as query parameters on a redirect.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// authorizeRequest is a validated request to /oauth/authorize.
+type authorizeRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	state         string
+	scopes        []string
+	codeChallenge string
+}
+
+// parseAuthorizeRequest checks the client and redirect uri first. Until both
+// are known to be good the error can only be shown to the user, after that
+// it is returned as an *oauthError to send back to the client.
+func (cfg *apiConfig) parseAuthorizeRequest(r *http.Request) (authorizeRequest, error) {
+	clientID, err := uuid.Parse(r.FormValue("client_id"))
+	if err != nil {
+		return authorizeRequest{}, errors.New("unknown client")
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return authorizeRequest{}, errors.New("unknown client")
+	}
+
+	req := authorizeRequest{
+		client:      client,
+		redirectURI: r.FormValue("redirect_uri"),
+		state:       r.FormValue("state"),
+	}
+
+	if req.redirectURI == "" && len(client.RedirectUris) == 1 {
+		req.redirectURI = client.RedirectUris[0]
+	}
+	if !contains(client.RedirectUris, req.redirectURI) {
+		return authorizeRequest{}, errors.New("redirect uri is not registered for this client")
+	}
+
+	if r.FormValue("response_type") != "code" {
+		return req, &oauthError{Code: "unsupported_response_type", Description: "response_type must be code"}
+	}
+
+	req.codeChallenge = r.FormValue("code_challenge")
+	if req.codeChallenge == "" {
+		return req, &oauthError{Code: "invalid_request", Description: "code_challenge is required"}
+	}
+	if r.FormValue("code_challenge_method") != "S256" {
+		return req, &oauthError{Code: "invalid_request", Description: "code_challenge_method must be S256"}
+	}
+
+	req.scopes = auth.ParseScope(r.FormValue("scope"))
+	if len(req.scopes) == 0 {
+		req.scopes = client.Scopes
+	}
+	for _, scope := range req.scopes {
+		if !contains(client.Scopes, scope) {
+			return req, &oauthError{Code: "invalid_scope", Description: "scope " + scope + " is not allowed for this client"}
+		}
+	}
+
+	return req, nil
+}
+
+func (cfg *apiConfig) handlerAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, err := cfg.parseAuthorizeRequest(r)
+	if err != nil {
+		cfg.respondAuthorizeError(w, r, req, err)
+		return
+	}
+
+	renderConsentPage(w, http.StatusOK, req, "")
+}
+
+func (cfg *apiConfig) handlerAuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	req, err := cfg.parseAuthorizeRequest(r)
+	if err != nil {
+		cfg.respondAuthorizeError(w, r, req, err)
+		return
+	}
+
+	if r.PostFormValue("action") != "allow" {
+		redirectWithParams(w, r, req.redirectURI, url.Values{
+			"error": {"access_denied"},
+			"state": {req.state},
+		})
+		return
+	}
+
+	email := r.PostFormValue("email")
+	emailKey := emailThrottleKey(email)
+	ipKey := ipThrottleKey(cfg.clientIP(r))
+
+	wait, err := cfg.reserveLoginAttempt(r.Context(), emailKey, ipKey)
+	if err != nil {
+		log.Printf("failed to check login throttle: %s", err)
+		renderConsentPage(w, http.StatusInternalServerError, req, "Something went wrong, please try again.")
+		return
+	}
+	if wait > 0 {
+		renderConsentPage(w, http.StatusTooManyRequests, req, "Too many failed attempts, try again later.")
+		return
+	}
+
+	dbUser, err := cfg.checkConsentCredentials(r, email)
+	if err != nil {
+		renderConsentPage(w, http.StatusUnauthorized, req, "Incorrect email, password or code.")
+		return
+	}
+	cfg.releaseLoginAttempt(r.Context(), emailKey, ipKey)
+	cfg.clearLoginFailures(r.Context(), emailKey)
+
+	if dbUser.DeletionRequestedAt.Valid {
+		// signing in to Chirpy itself cancels a deletion, authorizing an app doesn't
+		renderConsentPage(w, http.StatusForbidden, req, "This account is being deleted. Sign in to Chirpy to keep it.")
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("failed to make authorization code: %s", err)
+		renderConsentPage(w, http.StatusInternalServerError, req, "Something went wrong, please try again.")
+		return
+	}
+
+	err = cfg.db.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code, cfg.secret),
+		ClientID:      req.client.ID,
+		UserID:        dbUser.ID,
+		FamilyID:      uuid.New(),
+		RedirectUri:   req.redirectURI,
+		Scopes:        req.scopes,
+		CodeChallenge: req.codeChallenge,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		log.Printf("failed to save authorization code: %s", err)
+		renderConsentPage(w, http.StatusInternalServerError, req, "Something went wrong, please try again.")
+		return
+	}
+
+	redirectWithParams(w, r, req.redirectURI, url.Values{
+		"code":  {code},
+		"state": {req.state},
+	})
+}
+
+// checkConsentCredentials signs the user in from the consent form, including
+// their second factor when they have one.
+func (cfg *apiConfig) checkConsentCredentials(r *http.Request, email string) (database.User, error) {
+	dbUser, err := cfg.db.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	password := r.PostFormValue("password")
+	needsRehash, err := auth.CheckPasswordHash(password, dbUser.HashedPassword)
+	if err != nil {
+		return database.User{}, err
+	}
+	if needsRehash {
+		cfg.rehashPassword(r.Context(), dbUser.ID, password)
+	}
+
+	if dbUser.TotpEnabledAt.Valid {
+		ok, err := cfg.checkMFACode(r.Context(), dbUser, r.PostFormValue("code"))
+		if err != nil {
+			return database.User{}, err
+		}
+		if !ok {
+			return database.User{}, errors.New("invalid code")
+		}
+	}
+
+	return dbUser, nil
+}
+
+func (cfg *apiConfig) respondAuthorizeError(w http.ResponseWriter, r *http.Request, req authorizeRequest, err error) {
+	var oauthErr *oauthError
+	if !errors.As(err, &oauthErr) {
+		// without a trusted redirect uri there is nowhere safe to send the user
+		setConsentHeaders(w)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	redirectWithParams(w, r, req.redirectURI, url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+		"state":             {req.state},
+	})
+}
+
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect uri", http.StatusBadRequest)
+		return
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		if len(values) == 0 || values[0] == "" {
+			continue
+		}
+		query.Set(key, values[0])
+	}
+	u.RawQuery = query.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, oauthErr := cfg.authenticateClient(r)
+	if oauthErr != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, oauthErr)
+		return
+	}
+
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		cfg.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		cfg.exchangeRefreshToken(w, r, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "unsupported_grant_type"})
+	}
+}
+
+func (cfg *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	codeHash := auth.HashToken(r.PostFormValue("code"), cfg.secret)
+
+	code, err := cfg.db.UseAuthorizationCode(r.Context(), codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		// a code used twice may have been stolen, so whatever it was
+		// exchanged for the first time is revoked as well
+		used, err := cfg.db.GetAuthorizationCode(r.Context(), codeHash)
+		if err == nil && used.UsedAt.Valid {
+			log.Printf("authorization code reused for client %s, revoking token family %s", used.ClientID, used.FamilyID)
+			err = cfg.db.RevokeRefreshTokenFamily(r.Context(), used.FamilyID)
+			if err != nil {
+				log.Printf("failed to revoke token family %s: %s", used.FamilyID, err)
+			}
+			err = cfg.revokeSessionAccessTokens(r.Context(), used.FamilyID)
+			if err != nil {
+				log.Printf("failed to revoke access tokens for family %s: %s", used.FamilyID, err)
+			}
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "invalid or expired code"})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to use authorization code: %s", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+
+	if code.ClientID != client.ID || code.RedirectUri != r.PostFormValue("redirect_uri") {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "code was issued to another client or redirect uri"})
+		return
+	}
+	if !auth.VerifyPKCE(r.PostFormValue("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "code_verifier does not match"})
+		return
+	}
+
+	grant := refreshGrant{
+		userID:   code.UserID,
+		familyID: code.FamilyID,
+		clientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+		scopes:   code.Scopes,
+	}
+
+	accessToken, accessTokenID, err := cfg.makeAccessToken(grant)
+	if err != nil {
+		log.Printf("failed to make access token: %s", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+
+	refreshToken, _, err := cfg.issueRefreshToken(r, grant, accessTokenID)
+	if err != nil {
+		log.Printf("failed to create refresh token: %s", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+
+	respondWithOAuthTokens(w, accessToken, refreshToken, grant.scopes)
+}
+
+func (cfg *apiConfig) exchangeRefreshToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	dbToken, err := cfg.getActiveRefreshToken(r.Context(), r.PostFormValue("refresh_token"))
+	if errors.Is(err, errInvalidRefreshToken) {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to look up refresh token: %s", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+
+	if !dbToken.ClientID.Valid || dbToken.ClientID.UUID != client.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "refresh token was issued to another client"})
+		return
+	}
+
+	accessToken, refreshToken, err := cfg.rotateRefreshToken(r, dbToken)
+	if errors.Is(err, errInvalidRefreshToken) {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to rotate refresh token: %s", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+
+	respondWithOAuthTokens(w, accessToken, refreshToken, dbToken.Scopes)
+}
+
+// handlerOAuthRevoke implements RFC 7009. Revoking a refresh token ends the
+// whole grant, revoking an access token only stops that token.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	client, oauthErr := cfg.authenticateClient(r)
+	if oauthErr != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, oauthErr)
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_request", Description: "token is required"})
+		return
+	}
+
+	dbToken, err := cfg.db.GetRefreshToken(r.Context(), auth.HashToken(token, cfg.secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.revokeOAuthAccessToken(w, r, client, token)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to look up refresh token: %s", err)
+		respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{Code: "server_error"})
+		return
+	}
+
+	if !dbToken.ClientID.Valid || dbToken.ClientID.UUID != client.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "unauthorized_client", Description: "token was issued to another client"})
+		return
+	}
+
+	err = cfg.db.RevokeRefreshTokenFamily(r.Context(), dbToken.FamilyID)
+	if err != nil {
+		log.Printf("failed to revoke token family %s: %s", dbToken.FamilyID, err)
+		respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{Code: "server_error"})
+		return
+	}
+
+	err = cfg.revokeSessionAccessTokens(r.Context(), dbToken.FamilyID)
+	if err != nil {
+		log.Printf("failed to revoke access tokens for family %s: %s", dbToken.FamilyID, err)
+		respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{Code: "server_error"})
+		return
+	}
+
+	cfg.audit(r, auditSessionRevoked, dbToken.UserID, dbToken.UserID, map[string]interface{}{
+		"session_id": dbToken.FamilyID,
+		"client_id":  client.ID,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// revokeOAuthAccessToken is handlerOAuthRevoke for access tokens.
+func (cfg *apiConfig) revokeOAuthAccessToken(w http.ResponseWriter, r *http.Request, client database.OauthClient, token string) {
+	claims, err := cfg.keys.ParseDelegatedJWT(token)
+	if err != nil {
+		// unknown, expired and already revoked tokens aren't an error, the
+		// client's goal has been met
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if claims.ClientID != client.ID.String() {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "unauthorized_client", Description: "token was issued to another client"})
+		return
+	}
+
+	err = cfg.revokeAccessToken(r.Context(), claims)
+	if err != nil {
+		log.Printf("failed to revoke access token %s: %s", claims.ID, err)
+		respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{Code: "server_error"})
+		return
+	}
+
+	cfg.audit(r, auditTokenRevoked, claims.UserID, claims.UserID, map[string]interface{}{
+		"access_token_id": claims.ID,
+		"client_id":       client.ID,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateClient accepts client credentials through HTTP Basic auth or
+// the request body. Public clients only send their client_id.
+func (cfg *apiConfig) authenticateClient(r *http.Request) (database.OauthClient, *oauthError) {
+	invalid := &oauthError{Code: "invalid_client"}
+
+	clientIDStr, secret, ok := r.BasicAuth()
+	if !ok {
+		clientIDStr = r.PostFormValue("client_id")
+		secret = r.PostFormValue("client_secret")
+	}
+
+	clientID, err := uuid.Parse(clientIDStr)
+	if err != nil {
+		return database.OauthClient{}, invalid
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, invalid
+	}
+
+	if client.SecretHash.Valid {
+		if !hmac.Equal([]byte(auth.HashToken(secret, cfg.secret)), []byte(client.SecretHash.String)) {
+			return database.OauthClient{}, invalid
+		}
+	} else if secret != "" {
+		return database.OauthClient{}, invalid
+	}
+
+	return client, nil
+}
+
+func respondWithOAuthTokens(w http.ResponseWriter, accessToken, refreshToken string, scopes []string) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(accessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         strings.Join(scopes, " "),
+	})
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthErr *oauthError) {
+	w.Header().Set("Cache-Control", "no-store")
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Chirpy"`)
+	}
+
+	respondWithJSON(w, code, oauthErr)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// setConsentHeaders stops the consent page being framed by another site or
+// cached along with the user's answers.
+func setConsentHeaders(w http.ResponseWriter) {
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Authorize {{.ClientName}} - Chirpy</title>
+</head>
+<body>
+  <h1>{{.ClientName}} wants to use your Chirpy account</h1>
+  <p>If you allow it, {{.ClientName}} will be able to:</p>
+  <ul>
+    {{range .Scopes}}<li>{{.}}</li>
+    {{end}}
+  </ul>
+  {{if .Error}}<p role="alert"><strong>{{.Error}}</strong></p>{{end}}
+  <form method="post" action="/oauth/authorize">
+    <input type="hidden" name="response_type" value="code">
+    <input type="hidden" name="client_id" value="{{.ClientID}}">
+    <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+    <input type="hidden" name="scope" value="{{.Scope}}">
+    <input type="hidden" name="state" value="{{.State}}">
+    <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+    <input type="hidden" name="code_challenge_method" value="S256">
+    <p><label>Email <input type="email" name="email" autocomplete="username" required></label></p>
+    <p><label>Password <input type="password" name="password" autocomplete="current-password"></label></p>
+    <p><label>Two-factor code (if enabled) <input type="text" name="code" autocomplete="one-time-code"></label></p>
+    <button type="submit" name="action" value="allow">Allow</button>
+    <button type="submit" name="action" value="deny" formnovalidate>Deny</button>
+  </form>
+</body>
+</html>
+`))
+
+func renderConsentPage(w http.ResponseWriter, code int, req authorizeRequest, errMsg string) {
+	scopes := make([]string, len(req.scopes))
+	for i, scope := range req.scopes {
+		scopes[i] = scopeDescriptions[scope]
+	}
+
+	setConsentHeaders(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+
+	err := consentTemplate.Execute(w, map[string]interface{}{
+		"ClientName":    req.client.Name,
+		"ClientID":      req.client.ID,
+		"RedirectURI":   req.redirectURI,
+		"Scope":         strings.Join(req.scopes, " "),
+		"Scopes":        scopes,
+		"State":         req.state,
+		"CodeChallenge": req.codeChallenge,
+		"Error":         errMsg,
+	})
+	if err != nil {
+		log.Printf("failed to render consent page: %s", err)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/John-1005/Chirpy/internal/oidc"
+)
+
+const (
+	oidcStateCookie = "chirpy_oidc"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// oidcState is kept in an encrypted cookie between sending the user to the
+// identity provider and their coming back.
+type oidcState struct {
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (cfg *apiConfig) handlerOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidc == nil {
+		respondWithError(w, http.StatusNotFound, "single sign-on is not configured", nil)
+		return
+	}
+
+	state := oidcState{ExpiresAt: time.Now().Add(oidcStateTTL)}
+	for _, s := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		value, err := oidc.RandomString(32)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to start login", err)
+			return
+		}
+		*s = value
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start login", err)
+		return
+	}
+	sealed, err := auth.Encrypt(data, cfg.oidcStateKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start login", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    sealed,
+		Path:     "/api/login/oidc",
+		Expires:  state.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, cfg.oidc.AuthCodeURL(state.State, state.Nonce, oidc.PKCEChallenge(state.Verifier)), http.StatusFound)
+}
+
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidc == nil {
+		respondWithError(w, http.StatusNotFound, "single sign-on is not configured", nil)
+		return
+	}
+
+	state, err := cfg.readOIDCState(r)
+	// the state is single use whatever happens next
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcStateCookie,
+		Path:   "/api/login/oidc",
+		MaxAge: -1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "login session expired, please try again", err)
+		return
+	}
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		respondWithError(w, http.StatusBadRequest, "state does not match", nil)
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		respondWithError(w, http.StatusUnauthorized, "identity provider returned "+providerErr, nil)
+		return
+	}
+
+	token, err := cfg.oidc.Exchange(r.Context(), query.Get("code"), state.Verifier)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "failed to sign in with identity provider", err)
+		return
+	}
+
+	claims, err := cfg.oidc.VerifyIDToken(r.Context(), token.IDToken, state.Nonce)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid id token", err)
+		return
+	}
+
+	dbUser, err := cfg.userForIdentity(r, claims)
+	if errors.Is(err, errEmailNotVerified) {
+		respondWithError(w, http.StatusForbidden, "identity provider has not verified this email address", nil)
+		return
+	}
+	if errors.Is(err, errRegistrationClosed) || errors.Is(err, errInviteRequired) {
+		respondWithError(w, http.StatusForbidden, "no account found, sign up first", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to sign in", err)
+		return
+	}
+
+	cfg.loginUser(w, r, dbUser)
+}
+
+func (cfg *apiConfig) readOIDCState(r *http.Request) (oidcState, error) {
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return oidcState{}, err
+	}
+
+	data, err := auth.Decrypt(cookie.Value, cfg.oidcStateKey)
+	if err != nil {
+		return oidcState{}, err
+	}
+
+	var state oidcState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return oidcState{}, err
+	}
+	if state.ExpiresAt.Before(time.Now()) {
+		return oidcState{}, errors.New("login state expired")
+	}
+
+	return state, nil
+}
+
+var errEmailNotVerified = errors.New("email address not verified")
+
+// userForIdentity finds the user an identity provider account belongs to.
+// The first time an account is seen it is linked to the user with the same
+// verified email, or a new user is created for it.
+func (cfg *apiConfig) userForIdentity(r *http.Request, claims *oidc.Claims) (database.User, error) {
+	identity, err := cfg.db.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		err = cfg.db.TouchUserIdentity(r.Context(), database.TouchUserIdentityParams{
+			ID:    identity.ID,
+			Email: claims.Email,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+		return cfg.db.GetUserByID(r.Context(), identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	// linking by email is only safe when the provider vouches for it
+	if claims.Email == "" || !claims.EmailVerified {
+		return database.User{}, errEmailNotVerified
+	}
+
+	dbUser, err := cfg.db.GetUserByEmail(r.Context(), claims.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		dbUser, err = cfg.createSSOUser(r, claims.Email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = cfg.db.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		UserID:  dbUser.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if !dbUser.EmailVerifiedAt.Valid {
+		dbUser, err = cfg.db.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+			ID:    dbUser.ID,
+			Email: dbUser.Email,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	return dbUser, nil
+}
+
+// createSSOUser creates a user who signs in through the identity provider.
+// They get a random password nobody knows, which they can replace through a
+// password reset if they ever want one.
+func (cfg *apiConfig) createSSOUser(r *http.Request, email string) (database.User, error) {
+	password, err := auth.MakeRefreshToken()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	// there is nowhere to enter an invite code on the way back from the
+	// identity provider, so only open registration creates accounts here
+	return cfg.registerUser(r.Context(), database.CreateUserParams{
+		HashedPassword: hashedPassword,
+		Email:          email,
+	}, "")
+}
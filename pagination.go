@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/John-1005/Chirpy/internal/pagination"
+)
+
+// pageSize reads the limit query parameter, falling back to fallback and
+// capped at max. It returns false if limit isn't a positive number.
+func pageSize(r *http.Request, fallback, max int) (int32, bool) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return int32(fallback), true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	if n > max {
+		n = max
+	}
+	return int32(n), true
+}
+
+// setNextLink points the Link header at the page after cursor, keeping the
+// rest of the request's query.
+func setNextLink(w http.ResponseWriter, r *http.Request, cursor pagination.Cursor) {
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", cursor.Encode())
+	next.RawQuery = query.Encode()
+
+	w.Header().Set("Link", "<"+next.String()+`>; rel="next"`)
+}
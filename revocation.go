@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// loadRevocations reads the revocation list for the key ring's cache. Only
+// cutoffs newer than the access token lifetime can affect a token that
+// hasn't expired yet, so older ones are left out.
+func (cfg *apiConfig) loadRevocations(ctx context.Context) (auth.RevocationList, error) {
+	list := auth.RevocationList{
+		TokenIDs:   map[string]struct{}{},
+		ValidAfter: map[uuid.UUID]time.Time{},
+		Exempt:     map[string]struct{}{},
+	}
+
+	tokenIDs, err := cfg.db.ListRevokedAccessTokens(ctx)
+	if err != nil {
+		return auth.RevocationList{}, err
+	}
+	for _, id := range tokenIDs {
+		list.TokenIDs[id.String()] = struct{}{}
+	}
+
+	since := sql.NullTime{
+		Time:  time.Now().UTC().Add(-accessTokenTTL),
+		Valid: true,
+	}
+	cutoffs, err := cfg.db.ListTokenCutoffs(ctx, since)
+	if err != nil {
+		return auth.RevocationList{}, err
+	}
+	for _, cutoff := range cutoffs {
+		list.ValidAfter[cutoff.ID] = cutoff.TokensValidAfter.Time
+	}
+
+	// moving a cutoff revokes every refresh token the user had, so any still
+	// live belong to a login since then and their access tokens stay valid
+	exempt, err := cfg.db.ListAccessTokensAfterCutoffs(ctx, since)
+	if err != nil {
+		return auth.RevocationList{}, err
+	}
+	for _, id := range exempt {
+		list.Exempt[id.UUID.String()] = struct{}{}
+	}
+
+	return list, nil
+}
+
+// revokeUserAccessTokens stops every access token the user has been issued
+// so far from working. The cutoff is written in UTC, like the iat it is
+// compared against, rather than in whatever time zone the database uses.
+func (cfg *apiConfig) revokeUserAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	err := cfg.db.RevokeUserAccessTokens(ctx, database.RevokeUserAccessTokensParams{
+		ID:               userID,
+		TokensValidAfter: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.revocations.Invalidate()
+	return nil
+}
+
+// revokeSessionAccessTokens denylists the access tokens issued alongside a
+// session's refresh tokens.
+func (cfg *apiConfig) revokeSessionAccessTokens(ctx context.Context, familyID uuid.UUID) error {
+	err := cfg.db.RevokeSessionAccessTokens(ctx, database.RevokeSessionAccessTokensParams{
+		ExpiresAt: time.Now().Add(accessTokenTTL),
+		FamilyID:  familyID,
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.revocations.Invalidate()
+	return nil
+}
+
+// revokeOtherSessionAccessTokens is revokeSessionAccessTokens for every
+// session the user has except the one given.
+func (cfg *apiConfig) revokeOtherSessionAccessTokens(ctx context.Context, userID, familyID uuid.UUID) error {
+	err := cfg.db.RevokeOtherSessionAccessTokens(ctx, database.RevokeOtherSessionAccessTokensParams{
+		ExpiresAt: time.Now().Add(accessTokenTTL),
+		UserID:    userID,
+		FamilyID:  familyID,
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.revocations.Invalidate()
+	return nil
+}
+
+// revokeAccessToken denylists a single access token until it expires.
+func (cfg *apiConfig) revokeAccessToken(ctx context.Context, claims *auth.CustomClaims) error {
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(accessTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	err = cfg.db.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+		TokenID:   tokenID,
+		UserID:    claims.UserID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.revocations.Invalidate()
+	return nil
+}
+
+// revokeAccessTokenFor denylists the access token that was issued together
+// with dbToken.
+func (cfg *apiConfig) revokeAccessTokenFor(ctx context.Context, dbToken database.RefreshToken) error {
+	if !dbToken.AccessTokenID.Valid {
+		return nil
+	}
+
+	err := cfg.db.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+		TokenID:   dbToken.AccessTokenID.UUID,
+		UserID:    dbToken.UserID,
+		ExpiresAt: time.Now().Add(accessTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.revocations.Invalidate()
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// roleRank orders roles so that each one can do everything the roles below it
+// can.
+var roleRank = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+func hasRole(dbUser database.User, role string) bool {
+	return roleRank[dbUser.Role] >= roleRank[role]
+}
+
+// requireRole only lets requests through from a logged in user with at least
+// the given role, and hands next whoever that was. Personal access tokens are
+// never accepted.
+func (cfg *apiConfig) requireRole(role string, next func(http.ResponseWriter, *http.Request, principal)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := cfg.requireSession(w, r)
+		if !ok {
+			return
+		}
+
+		dbUser, err := cfg.db.GetUserByID(r.Context(), p.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "user not found", err)
+			return
+		}
+
+		if !hasRole(dbUser, role) {
+			respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+			return
+		}
+
+		next(w, r, p)
+	}
+}
+
+func (cfg *apiConfig) handlerSetUserRole(w http.ResponseWriter, r *http.Request, p principal) {
+
+	type request struct {
+		Role string `json:"role"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	if _, ok := roleRank[params.Role]; !ok {
+		respondWithError(w, http.StatusBadRequest, "role must be user, moderator or admin", nil)
+		return
+	}
+
+	dbUser, err := cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: params.Role,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update role", err)
+		return
+	}
+
+	log.Printf("user %s is now %s", dbUser.ID, dbUser.Role)
+
+	cfg.audit(r, auditRoleChanged, p.UserID, dbUser.ID, map[string]interface{}{
+		"role": dbUser.Role,
+	})
+
+	respondWithJSON(w, http.StatusOK, databaseUserToApi(dbUser))
+}
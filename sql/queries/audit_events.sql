@@ -0,0 +1,32 @@
+-- name: InsertAuditEvent :exec
+INSERT INTO audit_events (id, created_at, event_type, actor_id, target_id, ip_address, user_agent, details)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+);
+
+
+
+-- name: ListUserAuditEvents :many
+SELECT * FROM audit_events
+WHERE target_id = sqlc.arg(user_id)::uuid OR actor_id = sqlc.arg(user_id)::uuid
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg(event_type)::text IS NULL OR event_type = sqlc.narg(event_type))
+AND (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(target_id)::uuid IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
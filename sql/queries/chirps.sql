@@ -11,15 +11,34 @@ RETURNING *;
 
 
 
--- name: GetChirps :many
-SELECT * FROM chirps
-ORDER BY created_at;
+-- name: ListChirps :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+  OR (chirps.created_at, chirps.id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY chirps.created_at, chirps.id
+LIMIT sqlc.arg(max_results);
+
+
+
+-- name: ListChirpsDesc :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+  OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(max_results);
 
 
 
 -- name: GetChirpByID :one
-SELECT * FROM CHIRPS
-WHERE ID = $1;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.deletion_requested_at IS NULL;
 
 
 -- name: DeleteChirpByID :exec
@@ -27,7 +46,58 @@ DELETE from chirps
 WHERE id = $1 and user_id = $2;
 
 
--- name: GetChirpsByID :many
-SELECT * FROM CHIRPS
-WHERE user_id = $1;
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+
+
+-- name: SearchChirps :many
+SELECT chirps.*,
+  ts_rank(chirps.search, websearch_to_tsquery('english', sqlc.arg(query))) AS rank,
+  ts_headline('english',
+    replace(replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+    websearch_to_tsquery('english', sqlc.arg(query)),
+    'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS highlight
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.search @@ websearch_to_tsquery('english', sqlc.arg(query))
+AND users.deletion_requested_at IS NULL
+AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL OR chirps.created_at < sqlc.narg(until))
+AND (sqlc.narg(cursor_rank)::real IS NULL
+  OR (ts_rank(chirps.search, websearch_to_tsquery('english', sqlc.arg(query))), chirps.id)
+    < (sqlc.narg(cursor_rank), sqlc.narg(cursor_id)::uuid))
+ORDER BY rank DESC, chirps.id DESC
+LIMIT sqlc.arg(max_results);
+
+
 
+-- name: SearchChirpsRecent :many
+SELECT chirps.*,
+  ts_rank(chirps.search, websearch_to_tsquery('english', sqlc.arg(query))) AS rank,
+  ts_headline('english',
+    replace(replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+    websearch_to_tsquery('english', sqlc.arg(query)),
+    'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS highlight
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.search @@ websearch_to_tsquery('english', sqlc.arg(query))
+AND users.deletion_requested_at IS NULL
+AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL OR chirps.created_at < sqlc.narg(until))
+AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+  OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(max_results);
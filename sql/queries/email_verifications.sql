@@ -0,0 +1,17 @@
+-- name: CreateEmailVerification :exec
+INSERT INTO email_verifications (id, user_id, email, token_hash, created_at, expires_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  NOW(),
+  $4
+);
+
+
+
+-- name: UseEmailVerification :one
+UPDATE email_verifications SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
@@ -0,0 +1,50 @@
+-- name: CreateHashtags :exec
+INSERT INTO hashtags (id, tag, created_at)
+SELECT gen_random_uuid(), tag, NOW()
+FROM unnest(sqlc.arg(tags)::text[]) AS tag
+ON CONFLICT (tag) DO NOTHING;
+
+
+
+-- name: TagChirp :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+SELECT sqlc.arg(chirp_id), id, sqlc.arg(created_at)
+FROM hashtags
+WHERE tag = ANY(sqlc.arg(tags)::text[])
+ON CONFLICT DO NOTHING;
+
+
+
+-- name: UntagChirp :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+
+
+-- name: ListHashtagChirps :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN users ON users.id = chirps.user_id
+WHERE hashtags.tag = sqlc.arg(tag)
+AND users.deletion_requested_at IS NULL
+AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+  OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(max_results);
+
+
+
+-- name: ListTrendingHashtags :many
+SELECT hashtags.tag,
+  COUNT(*) AS uses,
+  SUM(power(0.5, EXTRACT(EPOCH FROM NOW() - chirp_hashtags.created_at) / sqlc.arg(half_life_seconds)::float8))::float8 AS score
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags.created_at > sqlc.arg(since)
+AND users.deletion_requested_at IS NULL
+GROUP BY hashtags.tag
+ORDER BY score DESC, hashtags.tag
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,68 @@
+-- name: CreateInviteCode :one
+INSERT INTO invite_codes (id, created_at, updated_at, created_by, code_hash, code_prefix, max_uses, expires_at)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5
+)
+RETURNING *;
+
+
+
+-- name: GetInviteCode :one
+SELECT * FROM invite_codes
+WHERE id = $1;
+
+
+
+-- name: ListInviteCodes :many
+SELECT * FROM invite_codes
+WHERE created_by = $1
+ORDER BY created_at DESC;
+
+
+
+-- name: ListInviteRedemptions :many
+SELECT r.invite_id, r.user_id, r.redeemed_at, u.email
+FROM invite_redemptions r
+JOIN invite_codes i ON i.id = r.invite_id
+JOIN users u ON u.id = r.user_id
+WHERE i.created_by = $1
+ORDER BY r.redeemed_at;
+
+
+
+-- name: CountOutstandingInvites :one
+SELECT COALESCE(SUM(max_uses - uses), 0)::int FROM invite_codes
+WHERE created_by = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW());
+
+
+
+-- name: RedeemInviteCode :one
+UPDATE invite_codes
+SET uses = uses + 1, updated_at = NOW()
+WHERE code_hash = $1 AND revoked_at IS NULL
+AND (expires_at IS NULL OR expires_at > NOW()) AND uses < max_uses
+RETURNING *;
+
+
+
+-- name: CreateInviteRedemption :exec
+INSERT INTO invite_redemptions (invite_id, user_id, redeemed_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+);
+
+
+
+-- name: RevokeInviteCode :exec
+UPDATE invite_codes
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
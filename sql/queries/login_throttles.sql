@@ -0,0 +1,36 @@
+-- name: GetLoginThrottles :many
+SELECT * FROM login_throttles
+WHERE key = ANY(sqlc.arg(keys)::text[]);
+
+
+
+-- name: EnsureLoginThrottle :exec
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES ($1, 0, $2)
+ON CONFLICT (key) DO NOTHING;
+
+
+
+-- name: GetLoginThrottleForUpdate :one
+SELECT * FROM login_throttles
+WHERE key = $1
+FOR UPDATE;
+
+
+
+-- name: UpdateLoginThrottle :exec
+UPDATE login_throttles
+SET failures = $2, last_failure_at = $3, blocked_until = $4
+WHERE key = $1;
+
+
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
+
+
+
+-- name: DeleteStaleLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE last_failure_at < $1 AND (blocked_until IS NULL OR blocked_until < NOW());
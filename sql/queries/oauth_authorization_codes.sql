@@ -0,0 +1,33 @@
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (id, code_hash, client_id, user_id, family_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  NOW(),
+  $8
+);
+
+
+
+-- name: UseAuthorizationCode :one
+UPDATE oauth_authorization_codes SET used_at = NOW()
+WHERE code_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+
+
+-- name: GetAuthorizationCode :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1;
+
+
+
+-- name: DeleteExpiredAuthorizationCodes :exec
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < $1;
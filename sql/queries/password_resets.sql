@@ -0,0 +1,22 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (id, user_id, token_hash, created_at, expires_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  NOW(),
+  $3
+);
+
+
+
+-- name: UsePasswordReset :one
+UPDATE password_resets SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+
+
+-- name: InvalidatePasswordResets :exec
+UPDATE password_resets SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
@@ -0,0 +1,13 @@
+-- name: RedeemPowChallenge :execrows
+INSERT INTO pow_redemptions (challenge_id, expires_at)
+VALUES (
+  $1,
+  $2
+)
+ON CONFLICT (challenge_id) DO NOTHING;
+
+
+
+-- name: DeleteExpiredPowRedemptions :exec
+DELETE FROM pow_redemptions
+WHERE expires_at < $1;
@@ -1,20 +1,29 @@
--- name: InsertRefreshToken :exec
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+-- name: InsertRefreshToken :one
+INSERT INTO refresh_tokens (id, token_hash, token_prefix, created_at, updated_at, user_id, expires_at, revoked_at, family_id, last_used_at, user_agent, ip_address, client_id, scopes, access_token_id)
 VALUES (
+  gen_random_uuid(),
   $1,
   $2,
   $3,
   $4,
   $5,
-  $6
-);
+  $6,
+  $7,
+  $8,
+  $9,
+  $10,
+  $11,
+  $12,
+  $13,
+  $14
+)
+RETURNING *;
 
 
 
 -- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
-FROM refresh_tokens
-WHERE token = $1;
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
 
 
 
@@ -22,5 +31,63 @@ WHERE token = $1;
 -- name: RevokeRefreshToken :one
 UPDATE refresh_tokens SET revoked_at = NOW(),
 updated_at = NOW()
-WHERE token = $1
+WHERE token_hash = $1
+RETURNING *;
+
+
+
+-- name: RotateRefreshToken :one
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW(),
+replaced_by = $2
+WHERE id = $1 AND revoked_at IS NULL
 RETURNING *;
+
+
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+
+
+-- name: RevokeOtherRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL;
+
+
+
+-- name: RevokeUserRefreshTokenFamily :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL;
+
+
+
+-- name: ListSessions :many
+SELECT rt.family_id,
+  (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = rt.family_id)::timestamp AS created_at,
+  rt.last_used_at,
+  rt.user_agent,
+  rt.ip_address,
+  rt.expires_at,
+  rt.client_id
+FROM refresh_tokens rt
+WHERE rt.user_id = $1 AND rt.revoked_at IS NULL AND rt.expires_at > NOW()
+ORDER BY rt.last_used_at DESC;
+
+
+
+-- name: ListAccessTokensAfterCutoffs :many
+SELECT rt.access_token_id FROM refresh_tokens rt
+JOIN users u ON u.id = rt.user_id
+WHERE u.tokens_valid_after > $1 AND rt.revoked_at IS NULL AND rt.access_token_id IS NOT NULL;
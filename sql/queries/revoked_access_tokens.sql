@@ -0,0 +1,49 @@
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (token_id, user_id, revoked_at, expires_at)
+VALUES (
+  $1,
+  $2,
+  NOW(),
+  $3
+)
+ON CONFLICT (token_id) DO NOTHING;
+
+
+
+-- name: RevokeSessionAccessTokens :exec
+INSERT INTO revoked_access_tokens (token_id, user_id, revoked_at, expires_at)
+SELECT access_token_id, user_id, NOW(), sqlc.arg(expires_at)::timestamp
+FROM refresh_tokens
+WHERE family_id = sqlc.arg(family_id) AND access_token_id IS NOT NULL
+ON CONFLICT (token_id) DO NOTHING;
+
+
+
+-- name: RevokeOtherSessionAccessTokens :exec
+INSERT INTO revoked_access_tokens (token_id, user_id, revoked_at, expires_at)
+SELECT access_token_id, user_id, NOW(), sqlc.arg(expires_at)::timestamp
+FROM refresh_tokens
+WHERE user_id = sqlc.arg(user_id) AND family_id <> sqlc.arg(family_id) AND access_token_id IS NOT NULL
+ON CONFLICT (token_id) DO NOTHING;
+
+
+
+-- name: RevokeClientAccessTokens :exec
+INSERT INTO revoked_access_tokens (token_id, user_id, revoked_at, expires_at)
+SELECT rt.access_token_id, rt.user_id, NOW(), sqlc.arg(expires_at)::timestamp
+FROM refresh_tokens rt
+JOIN oauth_clients c ON c.id = rt.client_id
+WHERE c.id = sqlc.arg(client_id) AND c.owner_id = sqlc.arg(owner_id) AND rt.access_token_id IS NOT NULL
+ON CONFLICT (token_id) DO NOTHING;
+
+
+
+-- name: ListRevokedAccessTokens :many
+SELECT token_id FROM revoked_access_tokens
+WHERE expires_at > NOW();
+
+
+
+-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens
+WHERE expires_at < $1;
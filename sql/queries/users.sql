@@ -17,9 +17,16 @@ WHERE email = $1;
 
 
 
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+
+
 -- name: UpdateUsers :one
 UPDATE users 
-SET updated_at = NOW(), email = $1, hashed_password = $2
+SET updated_at = NOW(), email = $1, hashed_password = $2,
+email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
 WHERE id = $3
 RETURNING *;
 
@@ -29,3 +36,121 @@ RETURNING *;
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1;
+
+
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET updated_at = NOW(), hashed_password = $2
+WHERE id = $1;
+
+
+
+-- name: MarkEmailVerified :one
+UPDATE users
+SET updated_at = NOW(), email_verified_at = NOW()
+WHERE id = $1 AND email = $2
+RETURNING *;
+
+
+
+-- name: SetTOTPSecret :exec
+UPDATE users
+SET updated_at = NOW(), totp_secret = $2, totp_enabled_at = NULL, totp_last_step = NULL
+WHERE id = $1 AND totp_enabled_at IS NULL;
+
+
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET updated_at = NOW(), totp_enabled_at = NOW()
+WHERE id = $1;
+
+
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET updated_at = NOW(), totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL
+WHERE id = $1;
+
+
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2);
+
+
+
+-- name: SetUserRole :one
+UPDATE users
+SET updated_at = NOW(), role = $2
+WHERE id = $1
+RETURNING *;
+
+
+
+-- name: SetUserRoleByEmail :execrows
+UPDATE users
+SET updated_at = NOW(), role = $2
+WHERE email = $1;
+
+
+
+-- name: RequestUserDeletion :one
+UPDATE users
+SET updated_at = NOW(), deletion_requested_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET updated_at = NOW(), deletion_requested_at = NULL
+WHERE id = $1;
+
+
+
+-- name: DeleteUsersRequestedBefore :execrows
+DELETE FROM users
+WHERE deletion_requested_at < $1;
+
+
+
+-- name: RevokeUserAccessTokens :exec
+UPDATE users
+SET updated_at = NOW(), tokens_valid_after = $2
+WHERE id = $1;
+
+
+
+-- name: ListTokenCutoffs :many
+SELECT id, tokens_valid_after FROM users
+WHERE tokens_valid_after > $1;
+
+
+
+-- name: CountUsersCreatedSince :one
+SELECT COUNT(*) FROM users
+WHERE created_at > $1;
+
+
+
+-- name: SetUserHandle :one
+UPDATE users
+SET handle = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE handle = sqlc.arg(handle)::text AND deletion_requested_at IS NULL;
+
+
+
+-- name: GetUsersByHandles :many
+SELECT id, handle FROM users
+WHERE handle = ANY(sqlc.arg(handles)::text[]) AND deletion_requested_at IS NULL;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- Existing rows hold plaintext tokens, so they are dropped and every
+-- session has to log in again.
+DROP TABLE refresh_tokens;
+
+CREATE TABLE refresh_tokens(
+  id UUID PRIMARY KEY,
+  token_hash TEXT NOT NULL UNIQUE,
+  token_prefix TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  family_id UUID NOT NULL,
+  replaced_by UUID REFERENCES refresh_tokens(id),
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens(family_id);
+
+
+
+-- +goose Down
+DROP TABLE refresh_tokens;
+
+CREATE TABLE refresh_tokens(
+  token TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  family_id UUID NOT NULL,
+  replaced_by TEXT REFERENCES refresh_tokens(token),
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens(family_id);
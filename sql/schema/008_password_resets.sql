@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE password_resets(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+
+
+-- +goose Down
+DROP TABLE password_resets;
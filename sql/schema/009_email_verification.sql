@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+CREATE TABLE email_verifications(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  email TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+
+
+-- +goose Down
+DROP TABLE email_verifications;
+
+ALTER TABLE users DROP COLUMN email_verified_at;
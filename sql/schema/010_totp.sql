@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN totp_secret TEXT;
+
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;
+
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT;
+
+CREATE TABLE mfa_recovery_codes(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  code_hash TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+
+
+-- +goose Down
+DROP TABLE mfa_recovery_codes;
+
+ALTER TABLE users DROP COLUMN totp_last_step;
+
+ALTER TABLE users DROP COLUMN totp_enabled_at;
+
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE login_throttles(
+  key TEXT PRIMARY KEY,
+  failures INTEGER NOT NULL,
+  last_failure_at TIMESTAMP NOT NULL,
+  blocked_until TIMESTAMP
+);
+
+
+
+-- +goose Down
+DROP TABLE login_throttles;
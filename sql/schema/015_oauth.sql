@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE oauth_clients(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  owner_id UUID NOT NULL,
+  name TEXT NOT NULL,
+  secret_hash TEXT,
+  redirect_uris TEXT[] NOT NULL,
+  scopes TEXT[] NOT NULL,
+  constraint fk_owner_id
+  FOREIGN KEY (owner_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_authorization_codes(
+  id UUID PRIMARY KEY,
+  code_hash TEXT NOT NULL UNIQUE,
+  client_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  family_id UUID NOT NULL,
+  redirect_uri TEXT NOT NULL,
+  scopes TEXT[] NOT NULL,
+  code_challenge TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  constraint fk_client_id
+  FOREIGN KEY (client_id)
+  REFERENCES oauth_clients(id) ON DELETE CASCADE,
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE refresh_tokens ADD COLUMN client_id UUID REFERENCES oauth_clients(id) ON DELETE CASCADE;
+
+ALTER TABLE refresh_tokens ADD COLUMN scopes TEXT[];
+
+
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN scopes;
+
+ALTER TABLE refresh_tokens DROP COLUMN client_id;
+
+DROP TABLE oauth_authorization_codes;
+
+DROP TABLE oauth_clients;
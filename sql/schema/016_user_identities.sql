@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE user_identities(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  last_login_at TIMESTAMP NOT NULL,
+  UNIQUE (issuer, subject),
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+
+
+-- +goose Down
+DROP TABLE user_identities;
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP;
+
+ALTER TABLE refresh_tokens ADD COLUMN access_token_id UUID;
+
+CREATE TABLE revoked_access_tokens (
+  token_id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  revoked_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+
+
+-- +goose Down
+DROP TABLE revoked_access_tokens;
+
+ALTER TABLE refresh_tokens DROP COLUMN access_token_id;
+
+ALTER TABLE users DROP COLUMN tokens_valid_after;
@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE invite_codes(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  created_by UUID NOT NULL,
+  code_hash TEXT NOT NULL UNIQUE,
+  code_prefix TEXT NOT NULL,
+  max_uses INTEGER NOT NULL CHECK (max_uses > 0),
+  uses INTEGER NOT NULL DEFAULT 0,
+  expires_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  constraint fk_created_by
+  FOREIGN KEY (created_by)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX invite_codes_created_by_idx ON invite_codes(created_by);
+
+CREATE TABLE invite_redemptions(
+  invite_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  redeemed_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (invite_id, user_id),
+  constraint fk_invite_id
+  FOREIGN KEY (invite_id)
+  REFERENCES invite_codes(id) ON DELETE CASCADE,
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+
+
+-- +goose Down
+DROP TABLE invite_redemptions;
+
+DROP TABLE invite_codes;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE pow_redemptions(
+  challenge_id TEXT PRIMARY KEY,
+  expires_at TIMESTAMP NOT NULL
+);
+
+
+
+-- +goose Down
+DROP TABLE pow_redemptions;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- actor and target aren't foreign keys, the trail has to outlive the users
+-- it mentions
+CREATE TABLE audit_events(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  event_type TEXT NOT NULL,
+  actor_id UUID,
+  target_id UUID,
+  ip_address TEXT NOT NULL,
+  user_agent TEXT NOT NULL,
+  details JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events(created_at);
+
+CREATE INDEX audit_events_actor_id_idx ON audit_events(actor_id, created_at);
+
+CREATE INDEX audit_events_target_id_idx ON audit_events(target_id, created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+
+
+-- +goose Down
+DROP TRIGGER audit_events_append_only ON audit_events;
+
+DROP FUNCTION audit_events_append_only;
+
+DROP TABLE audit_events;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- every body a chirp had before it was edited, created_at being when that
+-- body was written
+CREATE TABLE chirp_revisions(
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL,
+  body TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  replaced_at TIMESTAMP NOT NULL,
+  constraint fk_chirp_id
+  FOREIGN KEY (chirp_id)
+  REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_revisions_chirp_id_idx ON chirp_revisions(chirp_id, created_at);
+
+
+
+-- +goose Down
+DROP TABLE chirp_revisions;
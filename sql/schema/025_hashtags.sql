@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE hashtags(
+  id UUID PRIMARY KEY,
+  tag TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL
+);
+
+-- created_at is the chirp's, so trending doesn't need to join chirps to know
+-- when a tag was used
+CREATE TABLE chirp_hashtags(
+  chirp_id UUID NOT NULL,
+  hashtag_id UUID NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, hashtag_id),
+  constraint fk_chirp_id
+  FOREIGN KEY (chirp_id)
+  REFERENCES chirps(id) ON DELETE CASCADE,
+  constraint fk_hashtag_id
+  FOREIGN KEY (hashtag_id)
+  REFERENCES hashtags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_hashtags_hashtag_id_idx ON chirp_hashtags(hashtag_id, created_at);
+
+CREATE INDEX chirp_hashtags_created_at_idx ON chirp_hashtags(created_at);
+
+-- tag the chirps we already have, following the same rules as the entities
+-- package
+INSERT INTO hashtags (id, tag, created_at)
+SELECT gen_random_uuid(), tag, NOW()
+FROM (
+  SELECT DISTINCT lower(m[2]) AS tag
+  FROM chirps
+  CROSS JOIN LATERAL regexp_matches(chirps.body, '(^|[^[:alnum:]_&/#])#([[:alnum:]_]+)', 'g') AS m
+) tags
+WHERE tag ~ '[[:alpha:]]' AND char_length(tag) <= 64;
+
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+SELECT DISTINCT chirps.id, hashtags.id, chirps.created_at
+FROM chirps
+CROSS JOIN LATERAL regexp_matches(chirps.body, '(^|[^[:alnum:]_&/#])#([[:alnum:]_]+)', 'g') AS m
+JOIN hashtags ON hashtags.tag = lower(m[2]);
+
+
+
+-- +goose Down
+DROP TABLE chirp_hashtags;
+
+DROP TABLE hashtags;
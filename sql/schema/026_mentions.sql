@@ -0,0 +1,53 @@
+-- +goose Up
+-- handles are stored lowercased, so they are unique whatever case they were
+-- typed in
+ALTER TABLE users
+ADD COLUMN handle TEXT UNIQUE;
+
+CREATE TABLE chirp_mentions(
+  chirp_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  start_offset INTEGER NOT NULL,
+  end_offset INTEGER NOT NULL,
+  PRIMARY KEY (chirp_id, start_offset),
+  constraint fk_chirp_id
+  FOREIGN KEY (chirp_id)
+  REFERENCES chirps(id) ON DELETE CASCADE,
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions(user_id);
+
+CREATE TABLE notifications(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL,
+  type TEXT NOT NULL,
+  actor_id UUID NOT NULL,
+  chirp_id UUID NOT NULL,
+  read_at TIMESTAMP,
+  UNIQUE (user_id, chirp_id, type),
+  constraint fk_user_id
+  FOREIGN KEY (user_id)
+  REFERENCES users(id) ON DELETE CASCADE,
+  constraint fk_actor_id
+  FOREIGN KEY (actor_id)
+  REFERENCES users(id) ON DELETE CASCADE,
+  constraint fk_chirp_id
+  FOREIGN KEY (chirp_id)
+  REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications(user_id, created_at);
+
+
+
+-- +goose Down
+DROP TABLE notifications;
+
+DROP TABLE chirp_mentions;
+
+ALTER TABLE users
+DROP COLUMN handle;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- codes only have to be unique for their user, two users drawing the same
+-- one is fine
+ALTER TABLE mfa_recovery_codes
+DROP CONSTRAINT mfa_recovery_codes_code_hash_key;
+
+ALTER TABLE mfa_recovery_codes
+ADD CONSTRAINT mfa_recovery_codes_user_id_code_hash_key UNIQUE (user_id, code_hash);
+
+
+
+-- +goose Down
+ALTER TABLE mfa_recovery_codes
+DROP CONSTRAINT mfa_recovery_codes_user_id_code_hash_key;
+
+ALTER TABLE mfa_recovery_codes
+ADD CONSTRAINT mfa_recovery_codes_code_hash_key UNIQUE (code_hash);
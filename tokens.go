@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/John-1005/Chirpy/internal/auth"
+	"github.com/John-1005/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxTokenNameLength = 100
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func (cfg *apiConfig) handlerCreateToken(w http.ResponseWriter, r *http.Request) {
+
+	type request struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request", err)
+		return
+	}
+
+	if params.Name == "" || len(params.Name) > maxTokenNameLength {
+		respondWithError(w, http.StatusBadRequest, "name must be between 1 and 100 characters", nil)
+		return
+	}
+
+	scopes, err := auth.ValidateScopes(params.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if params.ExpiresAt.Before(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "expires_at must be in the future", nil)
+			return
+		}
+		expiresAt = sql.NullTime{Time: *params.ExpiresAt, Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to make token", err)
+		return
+	}
+
+	dbToken, err := cfg.db.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:      p.UserID,
+		Name:        params.Name,
+		TokenHash:   auth.HashToken(token, cfg.secret),
+		TokenPrefix: auth.TokenPrefix(token),
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to save token", err)
+		return
+	}
+
+	cfg.audit(r, auditTokenCreated, p.UserID, p.UserID, map[string]interface{}{
+		"token_id": dbToken.ID,
+		"prefix":   dbToken.TokenPrefix,
+		"scopes":   dbToken.Scopes,
+	})
+
+	// the token is only ever shown here, we keep nothing but its hash
+	resp := databaseTokenToApi(dbToken)
+	resp.Token = token
+
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerListTokens(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	dbTokens, err := cfg.db.ListPersonalAccessTokens(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "trouble accessing database", err)
+		return
+	}
+
+	tokens := make([]PersonalAccessToken, len(dbTokens))
+	for i, dbToken := range dbTokens {
+		tokens[i] = databaseTokenToApi(dbToken)
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) handlerRevokeToken(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid token id", err)
+		return
+	}
+
+	rows, err := cfg.db.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: p.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke token", err)
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "token not found", nil)
+		return
+	}
+
+	cfg.audit(r, auditTokenRevoked, p.UserID, p.UserID, map[string]interface{}{
+		"token_id": tokenID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func databaseTokenToApi(dbToken database.PersonalAccessToken) PersonalAccessToken {
+	token := PersonalAccessToken{
+		ID:        dbToken.ID,
+		Name:      dbToken.Name,
+		Prefix:    dbToken.TokenPrefix,
+		Scopes:    dbToken.Scopes,
+		CreatedAt: dbToken.CreatedAt,
+	}
+	if dbToken.ExpiresAt.Valid {
+		token.ExpiresAt = &dbToken.ExpiresAt.Time
+	}
+	if dbToken.LastUsedAt.Valid {
+		token.LastUsedAt = &dbToken.LastUsedAt.Time
+	}
+	return token
+}